@@ -0,0 +1,171 @@
+package cliopatra
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * CONSTANTS
+ */
+
+// Argument arity values other than a fixed count
+const (
+	ArityOptional = -1 // Zero or one value
+	ArityVariadic = -2 // Zero or more values. One or more if required. Takes the rest of the positional arguments.
+)
+
+/*
+ * TYPES
+ */
+
+// assignArguments assigns the positional arguments to the arguments of the command set. Arguments with a
+// fixed Position are assigned first and the rest take the remaining positionals in definition order.
+func (cs CommandSet) assignArguments(positionals []matchItem, args []matchItem, l *logger) error {
+	used := make([]bool, len(positionals))
+	trailing := []string{}
+	var errs ParseErrors
+
+	take := func(pk string, a *Argument, from int, count int) int {
+		taken := 0
+		for j := from; j < len(positionals) && taken < count; j++ {
+			if used[j] {
+				continue
+			}
+			used[j] = true
+			taken++
+			markMatched(args, positionals[j].Index)
+			if a.valueSet == false {
+				a.Index = positionals[j].Index
+			}
+			l.logf(LogTrace, "%0.3d  %q  assigned to argument %q", positionals[j].Index, positionals[j].Value, pk)
+			a.SetValue(positionals[j].Value)
+		}
+		return taken
+	}
+
+	for _, pk := range cs.parameterKeys() {
+		a, ok := cs.Parameters[pk].(*Argument)
+		if !ok {
+			continue
+		}
+		if a.Position == 0 {
+			trailing = append(trailing, pk)
+			continue
+		}
+		min, max := a.arityRange(len(positionals))
+		taken := 0
+		if int(a.Position) <= len(positionals) {
+			taken = take(pk, a, int(a.Position)-1, max)
+		}
+		if taken > 0 && taken < min {
+			errs = append(errs, &ParseError{Err: ErrMissingParameter, Key: pk})
+		}
+	}
+
+	for n, pk := range trailing {
+		a := cs.Parameters[pk].(*Argument)
+		remaining := 0
+		for _, u := range used {
+			if !u {
+				remaining++
+			}
+		}
+		min, max := a.arityRange(remaining)
+		if a.arity == ArityVariadic {
+			reserve := 0
+			for _, later := range trailing[n+1:] {
+				laterMin, _ := cs.Parameters[later].(*Argument).arityRange(0)
+				reserve += laterMin
+			}
+			max = remaining - reserve
+			if max < 0 {
+				max = 0
+			}
+		}
+		taken := take(pk, a, 0, max)
+		if taken > 0 && taken < min {
+			errs = append(errs, &ParseError{Err: ErrMissingParameter, Key: pk})
+		}
+	}
+
+	for j, u := range used {
+		if !u {
+			l.logf(LogTrace, "%0.3d  %q  no argument takes this positional", positionals[j].Index, positionals[j].Value)
+			errs = append(errs, &ParseError{Err: ErrUnexpectedArgument, Index: positionals[j].Index, Value: positionals[j].Value})
+		}
+	}
+
+	return errs.err()
+}
+
+// arityRange returns the minimum and maximum number of values the argument takes
+func (a *Argument) arityRange(available int) (int, int) {
+	switch {
+	case a.arity == ArityOptional:
+		return 0, 1
+	case a.arity == ArityVariadic:
+		min := 0
+		if a.IsRequired {
+			min = 1
+		}
+		return min, available
+	case a.arity < 1:
+		return 1, 1
+	}
+	return a.arity, a.arity
+}
+
+// looksLikeParameter returns true if the token begins with a parameter prefix of the command set.
+// A lone prefix, commonly meaning stdin, and negative numbers are positional arguments.
+func (cs CommandSet) looksLikeParameter(token string) bool {
+	prefixes := cs.Prefix
+	if cs.IsGNU || cs.IsRuneImp {
+		prefixes = append(append([]string{}, prefixes...), GNUPrefix)
+	}
+	for _, p := range prefixes {
+		if len(p) > 0 && strings.HasPrefix(token, p) && token != p {
+			if _, err := strconv.ParseFloat(token, 64); err == nil {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// parameterKeys returns the parameter keys in definition order. Parameters added directly to the
+// Parameters map follow in key order.
+func (cs CommandSet) parameterKeys() []string {
+	keys := make([]string, 0, len(cs.Parameters))
+	seen := map[string]bool{}
+	for _, pk := range cs.order {
+		if _, ok := cs.Parameters[pk]; ok && !seen[pk] {
+			keys = append(keys, pk)
+			seen[pk] = true
+		}
+	}
+	rest := []string{}
+	for pk := range cs.Parameters {
+		if !seen[pk] {
+			rest = append(rest, pk)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// markMatched marks the argument with the command line index as matched
+func markMatched(args []matchItem, index int) {
+	for i := range args {
+		if args[i].Index == index {
+			args[i].Matched = true
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cliopatra
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseArity(t *testing.T) {
+	tests := []struct {
+		name     string
+		arity    int
+		required bool
+		args     []string
+		want     []string
+		errs     []error
+	}{
+		{"one", 1, false, []string{"a"}, []string{"a"}, nil},
+		{"one missing", 1, true, nil, nil, []error{ErrMissingParameter}},
+		{"two", 2, false, []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"two extra", 2, false, []string{"a", "b", "c"}, []string{"a", "b"}, []error{ErrUnexpectedArgument}},
+		{"optional", ArityOptional, false, nil, nil, nil},
+		{"variadic", ArityVariadic, false, []string{"a", "b", "c"}, []string{"a", "b", "c"}, nil},
+		{"variadic required", ArityVariadic, true, nil, nil, []error{ErrMissingParameter}},
+		{"unexpected", 1, false, []string{"a", "b"}, []string{"a"}, []error{ErrUnexpectedArgument}},
+		{"after terminator", 2, false, []string{"a", "--", "-b"}, []string{"a", "-b"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, _ := c.AddArgument("files", []string{"files"}, "The files")
+			a.SetArity(tt.arity)
+			a.SetRequired(tt.required)
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			if got := a.GetValues(); fmt.Sprint(got) != fmt.Sprint(tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("GetValues() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+		errs []error
+	}{
+		{"fixed", []string{"a", "b"}, map[string]string{"src": "[a]", "dest": "[b]", "rest": "[]", "last": "[]"}, nil},
+		{"last reserved", []string{"a", "b", "c"}, map[string]string{"src": "[a]", "dest": "[b]", "rest": "[]", "last": "[c]"}, nil},
+		{"variadic", []string{"a", "b", "c", "d", "e"}, map[string]string{"src": "[a]", "dest": "[b]", "rest": "[c d]", "last": "[e]"}, nil},
+		{"missing", nil, map[string]string{"src": "[]"}, []error{ErrMissingParameter}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Defined out of order, the fixed positions are assigned first
+			rest, _ := c.AddArgument("rest", []string{"rest"}, "The rest")
+			rest.SetArity(ArityVariadic)
+			dest, _ := c.AddArgument("dest", []string{"dest"}, "The destination")
+			dest.SetPosition(2)
+			c.AddArgument("last", []string{"last"}, "The last")
+			src, _ := c.AddArgument("src", []string{"src"}, "The source")
+			src.SetPosition(1)
+			src.SetRequired(true)
+
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			for pk, want := range tt.want {
+				if got := fmt.Sprint(c.Parameters[pk].(*Argument).GetValues()); got != want {
+					t.Errorf("%s GetValues() = %s, want %s", pk, got, want)
+				}
+			}
+		})
+	}
+}
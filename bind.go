@@ -0,0 +1,373 @@
+package cliopatra
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	ErrorBindTarget = "the bind target must be a non-nil pointer to a struct"
+	ErrorBindTag    = "unknown cli tag setting"
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	ErrBindTarget = errors.New(ErrorBindTarget) // Returned by Bind when not given a pointer to a struct
+)
+
+/*
+ * TYPES
+ */
+
+// bindTag is the parsed cli struct tag of a field
+type bindTag struct {
+	argument   bool
+	command    bool
+	counter    bool
+	decrements string
+	key        string
+	max        int
+	min        int
+	names      []string
+	persistent bool
+	prefix     []string
+	required   bool
+	separator  string
+}
+
+// fieldValue is the Value storing a parameter value in a bound struct field. Slice and map fields
+// without a parser of their own collect the values of a repeatable option.
+type fieldValue struct {
+	collect bool
+	field   reflect.Value
+	initial reflect.Value
+	parse   func(s string) (reflect.Value, error)
+}
+
+func (f fieldValue) Set(s string) error {
+	if f.collect && f.field.Kind() == reflect.Map {
+		kv := strings.SplitN(s, MapSeparator, 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return errors.New(ErrorMapEntry)
+		}
+		v, err := f.parse(kv[1])
+		if err != nil {
+			return err
+		}
+		if f.field.IsNil() {
+			f.field.Set(reflect.MakeMap(f.field.Type()))
+		}
+		f.field.SetMapIndex(reflect.ValueOf(kv[0]).Convert(f.field.Type().Key()), v)
+		return nil
+	}
+
+	v, err := f.parse(s)
+	if err != nil {
+		return err
+	}
+	if f.collect {
+		v = reflect.Append(f.field, v)
+	}
+	f.field.Set(v)
+	return nil
+}
+
+func (f fieldValue) String() string {
+	if !f.field.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.field.Interface())
+}
+
+// reset restores the field to its value when bound
+func (f fieldValue) reset() {
+	f.field.Set(f.initial)
+}
+
+// Bind defines parameters from the fields of the struct pointed to by v and stores the parsed values in
+// the fields. Bool fields are flags, fields tagged as arguments are arguments and the rest are options.
+// Slice and map fields are repeatable options, maps taking key=value entries. Nested struct fields are
+// subcommands. Fields are configured with struct tags:
+//
+//	cli:"name=v,verbose"     the parameter names. Default: the field name in kebab case
+//	cli:"key=verbose"        the parameter key. Default: the first name
+//	cli:"prefix=+"           the parameter prefixes
+//	cli:"argument"           a positional argument instead of an option
+//	cli:"command"            a subcommand even if the struct type has a parser
+//	cli:"counter"            an int field counting the times the flag is given. i.e.: -vvv
+//	cli:"decrements=verbose" a bool field decrementing the count of an earlier counter field
+//	cli:"persistent"         inherited by subcommands
+//	cli:"required"           required on the command line
+//	cli:"separator=,"        splits the values of a slice or map field
+//	cli:"min=1;max=3"        the number of values of a slice or map field
+//	cli:"-"                  ignored
+//	env:"APP_VERBOSE"        environment variable names, without the command set prefix
+//	default:"false"          the default value
+//	help:"..."               the help info, or the summery of a subcommand
+//
+// Settings in the cli tag are separated by semicolons. i.e.: cli:"name=o,output;required"
+// Fields are only updated for the selected command set and its parents. Unset fields keep their value.
+func (cs *CommandSet) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrBindTarget
+	}
+	return cs.bindStruct(rv.Elem())
+}
+
+// bindStruct defines the parameters and subcommands for the fields of the struct
+func (cs *CommandSet) bindStruct(rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if len(sf.PkgPath) > 0 || sf.Tag.Get("cli") == "-" {
+			continue
+		}
+		tag, err := parseBindTag(sf)
+		if err != nil {
+			return err
+		}
+		field := rv.Field(i)
+
+		parse, err := fieldParser(sf.Type)
+		collect := false
+		if err != nil && (sf.Type.Kind() == reflect.Slice || sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String) {
+			// Slices and maps collect the values of a repeatable option
+			parse, err = fieldParser(sf.Type.Elem())
+			collect = true
+		}
+		if sf.Type.Kind() == reflect.Struct && (err != nil || tag.command) {
+			sub, err := cs.AddCommand(CommandSet{Name: tag.names[0], Summery: sf.Tag.Get("help")})
+			if err != nil {
+				return err
+			}
+			if err := sub.bindStruct(field); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", sf.Name, err)
+		}
+		initial := reflect.ValueOf(field.Interface())
+		if collect {
+			initial = reflect.Zero(sf.Type)
+		}
+		if err := cs.bindField(sf, tag, fieldValue{collect, field, initial, parse}); err != nil {
+			return fmt.Errorf("%s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// bindField defines the parameter for a struct field
+func (cs *CommandSet) bindField(sf reflect.StructField, tag bindTag, value fieldValue) error {
+	var pv CommandLineParameter
+	var prefix *[]string
+	if len(tag.prefix) > 0 {
+		prefix = &tag.prefix
+	}
+	help := sf.Tag.Get("help")
+
+	switch {
+	case tag.argument:
+		a, err := cs.AddArgument(tag.key, tag.names, help)
+		if err != nil {
+			return err
+		}
+		a.typed = value
+		pv = a
+	case sf.Type.Kind() == reflect.Bool || tag.counter:
+		f, err := cs.AddFlag(tag.key, tag.names, prefix, help)
+		if err != nil {
+			return err
+		}
+		f.typed = value
+		f.SetCounter(tag.counter)
+		if len(tag.decrements) > 0 {
+			counter, ok := cs.Parameters[tag.decrements].(*Flag)
+			if !ok {
+				return fmt.Errorf("%s: %q", ErrorFlagMissing, tag.decrements)
+			}
+			f.SetDecrement(counter)
+		}
+		pv = f
+	default:
+		o, err := cs.AddOption(tag.key, tag.names, prefix, help)
+		if err != nil {
+			return err
+		}
+		o.typed = value
+		if value.collect {
+			o.SetRepeatable(true)
+			o.SetMap(sf.Type.Kind() == reflect.Map)
+		}
+		if len(tag.separator) > 0 {
+			o.SetSeparator(tag.separator)
+		}
+		if tag.min > 0 || tag.max > 0 {
+			o.SetCount(tag.min, tag.max)
+		}
+		pv = o
+	}
+
+	if env, ok := sf.Tag.Lookup("env"); ok {
+		if err := pv.SetEnv(strings.Split(env, ",")); err != nil {
+			return err
+		}
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		if err := pv.SetDefault(def); err != nil {
+			return err
+		}
+	}
+	pv.SetRequired(tag.required)
+	if tag.persistent {
+		cs.SetPersistent(tag.key, true)
+	}
+	return nil
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// fieldParser returns the function converting a value to the type. The parsers registered for
+// AddOptionOf are used first, then encoding.TextUnmarshaler and finally the basic kinds. Integers are base 10.
+func fieldParser(t reflect.Type) (func(s string) (reflect.Value, error), error) {
+	parsersMu.RLock()
+	f, ok := parsers[t]
+	parsersMu.RUnlock()
+	if ok {
+		fn := reflect.ValueOf(f)
+		return func(s string) (reflect.Value, error) {
+			out := fn.Call([]reflect.Value{reflect.ValueOf(s)})
+			if err, _ := out[1].Interface().(error); err != nil {
+				return reflect.Value{}, err
+			}
+			return out[0], nil
+		}, nil
+	}
+
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return func(s string) (reflect.Value, error) {
+			v := reflect.New(t)
+			err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			return v.Elem(), err
+		}, nil
+	}
+
+	var conv func(s string) (interface{}, error)
+	switch t.Kind() {
+	case reflect.Bool:
+		conv = func(s string) (interface{}, error) { return truthyString(s), nil }
+	case reflect.Float32, reflect.Float64:
+		conv = func(s string) (interface{}, error) { return strconv.ParseFloat(s, t.Bits()) }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		conv = func(s string) (interface{}, error) { return strconv.ParseInt(s, 10, t.Bits()) }
+	case reflect.String:
+		conv = func(s string) (interface{}, error) { return s, nil }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		conv = func(s string) (interface{}, error) { return strconv.ParseUint(s, 10, t.Bits()) }
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrParserMissing, t)
+	}
+	return func(s string) (reflect.Value, error) {
+		v, err := conv(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v).Convert(t), nil
+	}, nil
+}
+
+// kebabCase converts a Go field name to a parameter name. i.e.: DryRun to dry-run
+func kebabCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Word boundary before an upper case letter following a lower case letter, or ending an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				b.WriteRune('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parseBindTag parses the cli tag of the struct field
+func parseBindTag(sf reflect.StructField) (bindTag, error) {
+	tag := bindTag{}
+	for _, setting := range strings.Split(sf.Tag.Get("cli"), ";") {
+		setting = strings.TrimSpace(setting)
+		if len(setting) == 0 {
+			continue
+		}
+		name, value := setting, ""
+		if i := strings.Index(setting, "="); i >= 0 {
+			name, value = setting[:i], setting[i+1:]
+		}
+		switch name {
+		case "argument":
+			tag.argument = true
+		case "command":
+			tag.command = true
+		case "counter":
+			tag.counter = true
+		case "decrements":
+			tag.decrements = value
+		case "key":
+			tag.key = value
+		case "max", "min":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return tag, fmt.Errorf("%s: %s: %q", sf.Name, ErrorBindTag, setting)
+			}
+			if name == "max" {
+				tag.max = n
+			} else {
+				tag.min = n
+			}
+		case "name":
+			tag.names = strings.Split(value, ",")
+		case "persistent":
+			tag.persistent = true
+		case "prefix":
+			tag.prefix = strings.Split(value, ",")
+		case "required":
+			tag.required = true
+		case "separator":
+			tag.separator = value
+		default:
+			return tag, fmt.Errorf("%s: %s: %q", sf.Name, ErrorBindTag, setting)
+		}
+	}
+
+	if len(tag.names) == 0 {
+		tag.names = []string{kebabCase(sf.Name)}
+	}
+	if len(tag.key) == 0 {
+		tag.key = tag.names[0]
+		// Prefer a word name for the key. i.e.: verbose over v
+		for _, name := range tag.names {
+			if len(name) > 1 {
+				tag.key = name
+				break
+			}
+		}
+	}
+	return tag, nil
+}
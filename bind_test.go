@@ -0,0 +1,115 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	type remote struct {
+		URL string `cli:"name=url;required" help:"The remote URL"`
+	}
+	type config struct {
+		Verbose int           `cli:"name=v,verbose;counter"`
+		Quiet   bool          `cli:"name=q,quiet;decrements=verbose"`
+		Output  string        `cli:"name=o,output" env:"OUTPUT" default:"out.txt"`
+		Timeout time.Duration `default:"5s"`
+		Retries int8
+		Tags    []string          `cli:"name=tag;separator=,"`
+		Labels  map[string]string `cli:"name=label"`
+		Source  string            `cli:"argument"`
+		Remote  remote            `help:"Manage remotes"`
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    config
+		command string
+		errs    []error
+	}{
+		{"defaults", nil, config{Output: "out.txt", Timeout: 5 * time.Second}, "app", nil},
+		{"values", []string{"-v", "-v", "-q", "--output", "x", "--timeout", "1m", "--retries", "010", "--tag", "a,b", "--label", "k=v", "in"},
+			config{Verbose: 1, Quiet: true, Output: "x", Timeout: time.Minute, Retries: 10, Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}, Source: "in"}, "app", nil},
+		{"counter inline", []string{"--verbose=2"}, config{Verbose: 2, Output: "out.txt", Timeout: 5 * time.Second}, "app", nil},
+		{"subcommand", []string{"remote", "--url", "u"}, config{Output: "out.txt", Timeout: 5 * time.Second, Remote: remote{URL: "u"}}, "remote", nil},
+		{"subcommand required", []string{"remote"}, config{Output: "out.txt", Timeout: 5 * time.Second}, "remote", []error{ErrMissingParameter}},
+		{"conversion", []string{"--timeout", "soon"}, config{Output: "out.txt"}, "app", []error{ErrConversion}},
+	}
+
+	c, err := New(CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := c.Bind(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same instance is parsed repeatedly so each parse must reset the bound fields
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			if c.Command.Name != tt.command {
+				t.Errorf("Command = %q, want %q", c.Command.Name, tt.command)
+			}
+			if fmt.Sprintf("%+v", cfg) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("bound %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+
+	if err := c.Bind(cfg); !errors.Is(err, ErrBindTarget) {
+		t.Errorf("Bind(struct) = %v, want %v", err, ErrBindTarget)
+	}
+}
+
+func TestFieldParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   interface{}
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"int", int(0), "010", "10", false},
+		{"int8", int8(0), "010", "10", false},
+		{"int8 range", int8(0), "200", "", true},
+		{"int16", int16(0), "-010", "-10", false},
+		{"int32 hex", int32(0), "0x10", "", true},
+		{"int64", int64(0), "010", "10", false},
+		{"uint", uint(0), "010", "10", false},
+		{"uint8", uint8(0), "010", "10", false},
+		{"uint16 binary", uint16(0), "0b10", "", true},
+		{"uint32", uint32(0), "010", "10", false},
+		{"uint64 octal", uint64(0), "0o10", "", true},
+		{"float32", float32(0), "1.5", "1.5", false},
+		{"bool", false, "true", "true", false},
+		{"string", "", "010", "010", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse, err := fieldParser(reflect.TypeOf(tt.field))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if v.Type() != reflect.TypeOf(tt.field) {
+				t.Errorf("parse(%q) type = %s, want %T", tt.in, v.Type(), tt.field)
+			}
+			if got := fmt.Sprint(v.Interface()); got != tt.want {
+				t.Errorf("parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	ErrorHelpRequested    = "help requested"
+	ErrorVersionRequested = "version requested"
+	HelpKey               = "help"    // The parameter key of the built-in help flag
+	VersionKey            = "version" // The parameter key of the built-in version flag
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	DefaultHelpNames    = []string{"h", "help"}             // The default names of the built-in help flag
+	DefaultVersionNames = []string{"version"}               // The default names of the built-in version flag
+	ErrHelp             = errors.New(ErrorHelpRequested)    // Returned by Parse when the help flag is given
+	ErrVersion          = errors.New(ErrorVersionRequested) // Returned by Parse when the version flag is given
+)
+
+/*
+ * TYPES
+ */
+
+// SetHelpNames defines the names of the built-in help flag. No names removes the help flag.
+func (c *Cliopatra) SetHelpNames(names []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.setBuiltin(HelpKey, c.helpFlag, names, "Show this help and exit")
+	c.helpFlag = f
+	return err
+}
+
+// SetOutput defines where Run writes help and version info. Default: os.Stdout
+func (c *Cliopatra) SetOutput(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.output = w
+}
+
+// SetVersion defines the version of the app and adds the built-in version flag if not already defined
+func (c *Cliopatra) SetVersion(version string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Version = version
+	if c.versionFlag != nil || c.versionRemoved {
+		return nil
+	}
+	f, err := c.setBuiltin(VersionKey, nil, DefaultVersionNames, "Show the version and exit")
+	c.versionFlag = f
+	return err
+}
+
+// SetVersionNames defines the names of the built-in version flag. No names removes the version flag.
+func (c *Cliopatra) SetVersionNames(names []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.versionRemoved = len(names) == 0
+	f, err := c.setBuiltin(VersionKey, c.versionFlag, names, "Show the version and exit")
+	c.versionFlag = f
+	return err
+}
+
+// builtinRequested returns ErrHelp or ErrVersion if the built-in help or version flag is true. i.e.:
+// --help=false does not request help.
+func (c *Cliopatra) builtinRequested() error {
+	if c.helpFlag != nil && c.helpFlag.GetFlag() {
+		return ErrHelp
+	}
+	if c.versionFlag != nil && c.versionFlag.GetFlag() {
+		return ErrVersion
+	}
+	return nil
+}
+
+// printBuiltin writes the help or version info requested
+func (c *Cliopatra) printBuiltin(command *CommandSet, err error) {
+	switch err {
+	case ErrHelp:
+		fmt.Fprint(c.output, command.GetHelp())
+	case ErrVersion:
+		name := c.Name
+		if len(name) == 0 {
+			name = filepath.Base(c.CliApp)
+		}
+		fmt.Fprintf(c.output, "%s %s\n", name, c.Version)
+	}
+}
+
+// setBuiltin replaces the built-in flag with one using the given names. The flag is removed if there are no
+// names. A parameter with the same key defined by the app is left alone, as are names used by the app.
+func (c *Cliopatra) setBuiltin(key string, current *Flag, names []string, help string) (*Flag, error) {
+	if pv, ok := c.Parameters[key]; ok {
+		if pv != CommandLineParameter(current) {
+			return nil, nil
+		}
+		c.CommandSet.removeParameter(key)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	f := &Flag{
+		Parameter: Parameter{
+			help:   help,
+			Prefix: []string{DefaultPrefix, GNUPrefix},
+		},
+		builtin: true,
+	}
+	// Names already used by the app are left to the app
+	unused := []string{}
+	for _, v := range names {
+		if c.CommandSet.nameUsed(f, v) == false {
+			unused = append(unused, v)
+		}
+	}
+	if len(unused) == 0 {
+		return nil, nil
+	}
+	if err := c.CommandSet.addParameter(key, unused, f); err != nil {
+		return nil, err
+	}
+	c.CommandSet.SetPersistent(key, true)
+	return f, nil
+}
+
+// nameUsed returns true if the parameter name is used by a flag or option of the command set
+func (cs CommandSet) nameUsed(pv CommandLineParameter, name string) bool {
+	for _, pk := range cs.parameterKeys() {
+		if _, ok := cs.Parameters[pk].(*Argument); ok {
+			continue
+		}
+		if _, used := cs.usedName(pv, name, cs.nameTokens(cs.Parameters[pk])); used {
+			return true
+		}
+	}
+	return false
+}
+
+// removeParameter removes the parameter from the command set
+func (cs *CommandSet) removeParameter(key string) {
+	delete(cs.Parameters, key)
+	for i, pk := range cs.order {
+		if pk == key {
+			cs.order = append(cs.order[:i], cs.order[i+1:]...)
+			break
+		}
+	}
+	cs.SetPersistent(key, false)
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// isBuiltin returns true if the parameter is the built-in help or version flag
+func isBuiltin(pv CommandLineParameter) bool {
+	f, ok := pv.(*Flag)
+	return ok && f.builtin
+}
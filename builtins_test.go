@@ -0,0 +1,153 @@
+package cliopatra
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRequested(t *testing.T) {
+	runParseTests(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}}, []parseTest{
+		{"help", []string{"--help"}, nil, []error{ErrHelp}},
+		{"help short", []string{"-h"}, nil, []error{ErrHelp}},
+		{"help with errors", []string{"--nope", "-h"}, nil, []error{ErrHelp}},
+		{"help true", []string{"--help=true"}, nil, []error{ErrHelp}},
+		{"help false", []string{"--help=false"}, nil, nil},
+	})
+}
+
+func TestBuiltinNames(t *testing.T) {
+	type hostConfig struct {
+		Host string `cli:"name=h,host"`
+	}
+
+	tests := []struct {
+		name  string
+		cs    CommandSet
+		setup func(c *Cliopatra) *CommandSet
+		args  []string
+		host  string
+		errs  []error
+	}{
+		{"option", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			c.AddOption("host", []string{"h", "host"}, nil, "The host")
+			return c.CommandSet
+		}, []string{"-h", "example"}, "example", nil},
+		{"option keeps --help", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			c.AddOption("host", []string{"h", "host"}, nil, "The host")
+			return c.CommandSet
+		}, []string{"--help"}, "", []error{ErrHelp}},
+		{"bind", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			c.Bind(&hostConfig{})
+			return c.CommandSet
+		}, []string{"-h", "example"}, "example", nil},
+		{"all names", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			c.AddOption("host", []string{"h", "help"}, nil, "The host")
+			return c.CommandSet
+		}, []string{"--help", "example"}, "example", nil},
+		{"subcommand", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+			sub.AddOption("host", []string{"h", "host"}, nil, "The host")
+			return sub
+		}, []string{"sub", "-h", "example"}, "example", nil},
+		{"subcommand keeps root help", CommandSet{}, func(c *Cliopatra) *CommandSet {
+			sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+			sub.AddOption("host", []string{"h", "host"}, nil, "The host")
+			return sub
+		}, []string{"-h"}, "", []error{ErrHelp}},
+		{"multics word", CommandSet{IsMultics: true, IsPosix: true, AllowPosixGroups: true}, func(c *Cliopatra) *CommandSet {
+			c.AddOption("host", []string{"host"}, nil, "The host")
+			return c.CommandSet
+		}, []string{"-host", "example"}, "example", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cs.Name = "app"
+			tt.cs.IsGNU = true
+			c, err := New(tt.cs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs := tt.setup(c)
+			host, ok := cs.Parameters["host"]
+			if !ok {
+				t.Fatalf("host not defined")
+			}
+			// Matching must not depend on map order
+			for i := 0; i < 20; i++ {
+				checkErrors(t, c.Parse(tt.args), tt.errs)
+				if got, _ := host.GetValue(); got != tt.host {
+					t.Fatalf("host GetValue() = %q, want %q", got, tt.host)
+				}
+			}
+		})
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	c, err := New(CommandSet{Name: "app", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbose, _ := c.AddFlag("verbose", []string{"v", "version-check"}, nil, "")
+	if err := c.SetVersionNames([]string{"v", "version"}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetVersion("1.2.3")
+
+	checkErrors(t, c.Parse([]string{"--version"}), []error{ErrVersion})
+	checkErrors(t, c.Parse([]string{"-v"}), nil)
+	if verbose.GetFlag() == false {
+		t.Errorf("-v did not set the app flag")
+	}
+
+	if err := c.SetHelpNames(nil); err != nil {
+		t.Fatal(err)
+	}
+	checkErrors(t, c.Parse([]string{"--help"}), []error{ErrUnknownParameter})
+}
+
+func TestRunBuiltins(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		exit   int
+		err    error
+	}{
+		{"help", []string{"--help"}, "USAGE:\n  app [-h] [--version]\n", 0, nil},
+		{"version", []string{"--version"}, "app 1.2.3\n", 0, nil},
+		{"none", nil, "", -1, nil},
+		{"error", []string{"--nope"}, "", -1, ErrUnknownParameter},
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetVersion("1.2.3")
+			var out bytes.Buffer
+			c.SetOutput(&out)
+			exit := -1
+			c.exit = func(code int) { exit = code }
+
+			os.Args = append([]string{"app"}, tt.args...)
+			if err := c.Run(); !errors.Is(err, tt.err) {
+				t.Errorf("Run() = %v, want %v", err, tt.err)
+			}
+			if exit != tt.exit {
+				t.Errorf("exit code = %d, want %d", exit, tt.exit)
+			}
+			if !strings.Contains(out.String(), tt.output) || len(tt.output) == 0 && out.Len() > 0 {
+				t.Errorf("output = %q, want %q", out.String(), tt.output)
+			}
+		})
+	}
+}
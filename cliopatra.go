@@ -3,11 +3,12 @@ package cliopatra
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"math/bits"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 /*
@@ -17,13 +18,31 @@ const (
 	DefaultPrefix           = "-"
 	DefaultSuffix           = ""
 	ErrorArgumentMissing    = "argument not set"
+	ErrorArgumentUnexpected = "unexpected argument"
+	ErrorConfigLoad         = "the config file could not be loaded"
+	ErrorCommandExists      = "the command name is already defined"
+	ErrorCommandNameEmpty   = "the command name must not be empty (zero length or all whitespace)"
 	ErrorFlagMissing        = "the flag was not set"
+	ErrorKeyExists          = "the parameter key is already defined"
 	ErrorKeyLengthZero      = "the key length must be greater than zero"
+	ErrorMulticsAmbiguous   = "the Multics name is ambiguous with a POSIX short name"
+	ErrorMulticsDuplicate   = "the Multics name is already defined with different word separators"
+	ErrorNameExists         = "the parameter name is already defined"
 	ErrorNameLengthZero     = "the parameter name length must be greater than zero"
 	ErrorOptionMissing      = "the option was not set"
 	ErrorOptionValueMissing = "the option's value was not set"
+	ErrorParameterRequired  = "the required parameter was not set"
+	ErrorParameterUnknown   = "unknown parameter"
+	ErrorRuneImpPosixGroups = "RuneImp grouping can not be combined with POSIX groups"
+	ErrorValueConversion    = "the value could not be converted"
 	ErrorEnvDefaultSetEmpty = "the environment variable default name must not be empty (zero length or all whitespace)"
+	GNUPrefix               = "--"
+	GNUValueSeparator       = "="
+	MulticsSeparator        = "_"
+	OptionTerminator        = "--"
 	PackageVersion          = "0.1.0-alpha"
+	PosixPrefix             = "-"
+	RuneImpGroupPrefix      = "--"
 	StringTruthyOne         = "1"
 	StringTruthyTrue        = "true"
 	StringTruthyYes         = "yes"
@@ -33,8 +52,14 @@ const (
  * DERIVED CONSTANTS
  */
 var (
-	cliopatraInstance *Cliopatra // Singleton
-	intSize           = bits.UintSize
+	intSize               = bits.UintSize
+	ErrConfig             = errors.New(ErrorConfigLoad)         // The config file could not be read or parsed
+	ErrConversion         = errors.New(ErrorValueConversion)    // The parameter value could not be converted to the requested type
+	ErrMissingParameter   = errors.New(ErrorParameterRequired)  // A required parameter was not given
+	ErrMissingValue       = errors.New(ErrorOptionValueMissing) // An option was given without its value
+	ErrRuneImpPosixGroups = errors.New(ErrorRuneImpPosixGroups) // RuneImp grouping and POSIX groups were both enabled
+	ErrUnexpectedArgument = errors.New(ErrorArgumentUnexpected) // A positional argument was given that no argument takes
+	ErrUnknownParameter   = errors.New(ErrorParameterUnknown)   // A command line argument did not match any parameter
 )
 
 /*
@@ -44,46 +69,111 @@ var (
 // Cliopatra is the extended root CommandSet
 type Cliopatra struct {
 	*CommandSet
-	CliApp string
-}
-
-// // GetHelp returns the help info for the Cliopatra instance
-// func (c *Cliopatra) GetHelp() string {
-// 	help := c.CommandSet.Name
-// 	for pk, pv := range c.Parameters {
-// 		help += fmt.Sprintf(`
-// USAGE: %q
-
-// OPTIONS:
-// `, c.CliApp)
-// 		// help += fmt.Sprintf("  %-20s  %s\n", pk, pv.GetHelp())
-// 		// help += fmt.Sprintf("  %-20s  %q\n", pk, pv.Parameter.Name)
-// 		prefixes := ""
-// 		for _, v := range pv.GetName() {
-// 			// fmt.Printf("  %s  %q\n", pk, v)
-// 			for _, p := range pv.GetPrefix() {
-// 				// fmt.Printf("  %s    %q\n", pk, p)
-// 				prefixes += " | " + p + v
-// 			}
-// 		}
-// 		help += fmt.Sprintf("  %-20s  %s\n", prefixes[3:], pv.GetHelp()+" ("+pk+")")
-// 	}
-// 	return help
-// }
-
-// Run processes the command line parameters
-func (c *Cliopatra) Run() {
+	CliApp         string
+	Command        *CommandSet  // The command set selected by the last run. The root command set if no subcommand matched.
+	Version        string       // The version of the app shown by the built-in version flag
+	configFile     string       // The default config file path
+	configFormat   ConfigFormat // The config file format
+	configOption   string       // The key of the option that replaces the config file path
+	exit           func(int)    // Called by Run after showing help or version info. Default: os.Exit
+	helpFlag       *Flag        // The built-in help flag. Nil if removed or replaced by the app.
+	logger         logger       // Logging while parsing. Off by default.
+	mu             sync.Mutex   // Serializes parsing and handlers as parameter values are stored in the command set tree
+	output         io.Writer    // Where Run writes help and version info. Default: os.Stdout
+	versionFlag    *Flag        // The built-in version flag. Nil until a version is set, or if removed or replaced by the app.
+	versionRemoved bool         // If the version flag was removed with SetVersionNames
+}
+
+// Parse processes the given command line arguments, not including the command itself. Parameter values
+// from any previous parse are cleared first so Parse may be called repeatedly. The selected command set
+// is available as Command afterwards. Parse failures are returned as a *ParseError. ErrHelp or ErrVersion
+// is returned if the built-in help or version flag is given.
+//
+// The parsed values are stored in the parameters of the instance, so an instance holds the result of one
+// parse at a time. Concurrent calls on the same instance are serialized, but values must not be read while
+// another goroutine parses with the same instance. Use an instance per goroutine for concurrent parses.
+// Reading values concurrently once parsing is done is safe.
+func (c *Cliopatra) Parse(args []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.parse(args)
+	return err
+}
+
+// Run processes the command line parameters from os.Args and calls the handler of the selected command set.
+// Parse failures are returned as a *ParseError. If the built-in help or version flag is given the info is
+// written to the output and the program exits with code 0. The same is done with the completion candidates
+// when called by a completion script with the hidden __complete command. The instance stays locked while
+// the handler runs so concurrent calls to Parse or Run wait for it. A handler must not call Parse or Run
+// on the same instance.
+func (c *Cliopatra) Run() error {
+	if len(os.Args) > 1 && os.Args[1] == CompleteCommand {
+		candidates := c.Complete(os.Args[2:])
+		for _, candidate := range candidates {
+			fmt.Fprintln(c.output, candidate)
+		}
+		c.exit(0)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.CliApp = os.Args[0]
-	for i, v := range os.Args[1:] {
-		log.Printf("%0.3d  %q\n", i, v)
-		matchList = append(matchList, matchItem{
+	command, err := c.parse(os.Args[1:])
+	if err == ErrHelp || err == ErrVersion {
+		c.printBuiltin(command, err)
+		c.exit(0)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if command.Handler != nil {
+		return command.Handler(command)
+	}
+	return nil
+}
+
+// parse matches the arguments against the command set tree and returns the selected command set
+func (c *Cliopatra) parse(args []string) (*CommandSet, error) {
+	list := make([]matchItem, 0, len(args))
+	for i, v := range args {
+		c.logger.logf(LogDebug, "%0.3d  %q", i+1, v)
+		list = append(list, matchItem{
 			Index:   i + 1,
 			Matched: false,
 			Value:   v,
 		})
 	}
-	fmt.Println()
-	c.CommandSet.MatchCommandLine(matchList)
+
+	c.CommandSet.reset()
+	command, err := c.CommandSet.matchCommandLine(list, &c.logger)
+	if command == nil {
+		command = c.CommandSet
+	}
+	c.Command = command
+	switch err.(type) {
+	case nil, *ParseError, ParseErrors:
+	default:
+		c.logger.logf(LogError, "%s", err)
+		return command, err
+	}
+	if berr := c.builtinRequested(); berr != nil {
+		c.logger.logf(LogInfo, "%s for command set %q", berr, command.Name)
+		return command, berr
+	}
+	errs := ParseErrors{}.append(err)
+
+	c.CommandSet.loadEnv()
+	errs = errs.append(c.loadConfig(command))
+	errs = errs.append(command.setTypedValues())
+	errs = errs.append(command.checkValues())
+	c.logger.logf(LogInfo, "command set %q selected", command.Name)
+	errs = errs.append(command.checkRequired())
+	for _, e := range errs {
+		c.logger.logf(LogError, "%s", e)
+	}
+	return command, errs.err()
 }
 
 type matchItem struct {
@@ -94,6 +184,7 @@ type matchItem struct {
 
 // CommandLineParameter is the data interface for command line parameters
 type CommandLineParameter interface {
+	GetEnv() []string            // Returns the environment variable names, without the command set prefix, checked for a default value
 	GetFlag() bool               // Returns the boolean value of a flag
 	GetHelp() string             // Returns the help info for the parameter
 	GetInt() (int, error)        // Returns the value as a system integer
@@ -103,109 +194,563 @@ type CommandLineParameter interface {
 	GetUint() (uint, error)      // Returns the value as a system unsigned integer
 	GetValue() (string, error)   // Returns the value as a string
 	SetDefault(string) error     // Defines the default value to use if there is no value given on the command line and no environment variable default found
+	SetEnv([]string) error       // Defines the environment variable names, without the command set prefix, checked in order for a default value
 	SetFlag()                    // Defined the flag was used on the command line
 	SetKey(string) error         // Defines the key name to reference in code
 	SetName([]string) error      // Defines the parameter name(s) allowed on the command line
 	SetValue(string)             // Defines the parameter value found on the command line
+	SetConfigPreferred(bool)     // Defines if a config file default is preferred over an environment variable default
 	SetPrefix([]string, bool)    // Defines allowed alternate or custom prefixes to be used instead of, or in addition to, the command set prefix(s). Default prefix is a hyphen.
 	SetRequired(bool)            // Defines the parameter as required input. Errors if not present on the command line.
 }
 
+// CommandHandler is the function called when its command set is selected by the command line
+type CommandHandler func(cs *CommandSet) error
+
 // CommandSet is the data type for command line subcommand
 type CommandSet struct {
-	AllowPosixGroups bool   // Allow POSIX option groups?
-	Description      string // The long description to display to the user
-	Help             string // The help information to display to the user
-	IsGNU            bool   // Does the parameter conform to the GNU specification
-	IsMultics        bool   // Does the parameter conform to the Multics specification
-	IsPosix          bool   // Does the parameter conform to the POSIX specification
-	IsRuneImp        bool   // Does the parameter conform to the RuneImp specification
-	Name             string // The name of the command set
+	AllowPosixGroups bool                   // Allow POSIX option groups?
+	Commands         map[string]*CommandSet // The subcommands of the command set
+	Description      string                 // The long description to display to the user
+	EnvPrefix        string                 // The prefix added to parameter environment variable names. i.e.: MYAPP_
+	Handler          CommandHandler         // The function to call when the command set is selected
+	Help             string                 // The help information to display to the user
+	HelpTemplate     string                 // The text/template for help output. Default: empty (the parent's template, or DefaultHelpTemplate)
+	HelpWidth        int                    // The width to wrap help output to. Default: 0 (the parent's width, or the terminal width, or COLUMNS, or 80)
+	IsGNU            bool                   // Does the parameter conform to the GNU specification
+	IsMultics        bool                   // Does the parameter conform to the Multics specification
+	IsPosix          bool                   // Does the parameter conform to the POSIX specification
+	IsRuneImp        bool                   // Does the parameter conform to the RuneImp specification
+	Name             string                 // The name of the command set
 	Parameters       map[string]CommandLineParameter
+	commandOrder     []string // Subcommand names in definition order
+	order            []string // Parameter keys in definition order
+	Persistent       []string // List of parameter keys inherited by subcommands
 	Prefix           []string // List of allowed parameter prefixes. Mostly used for options/flags. Though occasionally used for arguments.
 	Suffix           []string // List of allowed parameter suffixes. Mostly used for arguments. Though occasionally used for options/flags.
 	Summery          string   // The short description to display to the user
+	parent           *CommandSet
 }
 
-// AddFlag defines a flag parameter for a command set
-func (cs CommandSet) AddFlag(key string, name []string, prefix *[]string, help string) {
-	p := cs.Prefix
-	if prefix != nil && len(*prefix) > 0 {
-		p = *prefix
+// AddArgument defines a positional argument for a command set. The names are used for help output only.
+// Arguments take the positional arguments left after flags, options and subcommands are matched.
+func (cs *CommandSet) AddArgument(key string, name []string, help string) (*Argument, error) {
+	a := &Argument{
+		Parameter: Parameter{
+			help: help,
+		},
+		arity: 1,
+	}
+	if err := cs.addParameter(key, name, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// AddCommand defines a subcommand for a command set. Prefixes and suffixes are inherited from the parent
+// command set if the subcommand has none. The parameter name conventions (AllowPosixGroups, IsGNU,
+// IsMultics, IsPosix and IsRuneImp) enabled on the parent are enabled on the subcommand as well, so a
+// subcommand may add conventions but can not turn off those of its parent.
+func (cs *CommandSet) AddCommand(sub CommandSet) (*CommandSet, error) {
+	name := strings.TrimSpace(sub.Name)
+	if len(name) == 0 {
+		return nil, errors.New(ErrorCommandNameEmpty)
+	}
+	if _, ok := cs.Commands[name]; ok {
+		return nil, fmt.Errorf("%s: %q", ErrorCommandExists, name)
+	}
+
+	sub.Name = name
+	sub.Parameters = make(map[string]CommandLineParameter)
+	if len(sub.Prefix) == 0 {
+		sub.Prefix = append([]string{}, cs.Prefix...)
+	}
+	if len(sub.Suffix) == 0 {
+		sub.Suffix = append([]string{}, cs.Suffix...)
+	}
+	sub.AllowPosixGroups = sub.AllowPosixGroups || cs.AllowPosixGroups
+	sub.IsGNU = sub.IsGNU || cs.IsGNU
+	sub.IsMultics = sub.IsMultics || cs.IsMultics
+	sub.IsPosix = sub.IsPosix || cs.IsPosix
+	sub.IsRuneImp = sub.IsRuneImp || cs.IsRuneImp
+	if sub.IsRuneImp && sub.AllowPosixGroups {
+		return nil, fmt.Errorf("%w: command set %q", ErrRuneImpPosixGroups, name)
 	}
+	sub.parent = cs
 
-	cs.Parameters[key] = &Flag{
+	if cs.Commands == nil {
+		cs.Commands = make(map[string]*CommandSet)
+	}
+	cs.Commands[name] = &sub
+	cs.commandOrder = append(cs.commandOrder, name)
+
+	return &sub, nil
+}
+
+// AddFlag defines a flag parameter for a command set. The command set prefixes are used if prefix is nil or empty.
+func (cs *CommandSet) AddFlag(key string, name []string, prefix *[]string, help string) (*Flag, error) {
+	f := &Flag{
 		Parameter: Parameter{
 			help:   help,
-			Name:   name,
-			Prefix: p,
+			Prefix: cs.parameterPrefix(prefix),
 		},
 		defaultValue: false,
 	}
+	if err := cs.addParameter(key, name, f); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
-// GetHelp returns the help info for the command set
-func (cs CommandSet) GetHelp() string {
-	help := cs.Name
-	help += fmt.Sprintf(`
+// AddOption defines an option parameter for a command set. The command set prefixes are used if prefix is nil or empty.
+func (cs *CommandSet) AddOption(key string, name []string, prefix *[]string, help string) (*Option, error) {
+	o := &Option{
+		Parameter: Parameter{
+			help:          help,
+			Prefix:        cs.parameterPrefix(prefix),
+			valueRequired: true,
+		},
+	}
+	if err := cs.addParameter(key, name, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
 
-OPTIONS:
-`)
-	for pk, pv := range cs.Parameters {
-		// help += fmt.Sprintf("  %-20s  %s\n", pk, pv.GetHelp())
-		// help += fmt.Sprintf("  %-20s  %q\n", pk, pv.Parameter.Name)
-		prefixes := ""
+// addParameter validates the key and names of the parameter and adds it to the command set
+func (cs *CommandSet) addParameter(key string, name []string, pv CommandLineParameter) error {
+	if err := pv.SetKey(strings.TrimSpace(key)); err != nil {
+		return err
+	}
+	key = strings.TrimSpace(key)
+	if _, ok := cs.Parameters[key]; ok {
+		return fmt.Errorf("%s: %q", ErrorKeyExists, key)
+	}
+	if _, ok := pv.(*Argument); ok == false && len(name) == 0 {
+		return errors.New(ErrorNameLengthZero)
+	}
+	if len(name) > 0 {
+		if err := pv.SetName(name); err != nil {
+			return err
+		}
+	}
+	cs.yieldBuiltinNames(pv)
+	if err := cs.checkNames(key, pv); err != nil {
+		return err
+	}
+	if isBuiltin(pv) == false {
+		if err := cs.checkMulticsNames(key, name); err != nil {
+			return err
+		}
+	}
+
+	cs.order = append(cs.order, key)
+	cs.Parameters[key] = pv
+	return nil
+}
+
+// parameterPrefix returns a copy of the given prefixes or the command set prefixes if none are given
+func (cs CommandSet) parameterPrefix(prefix *[]string) []string {
+	p := cs.Prefix
+	if prefix != nil && len(*prefix) > 0 {
+		p = *prefix
+	}
+	return append([]string{}, p...)
+}
+
+// checkNames reports a command line name of the parameter, compared along with its prefix, that is already
+// used by another parameter of the command set or one it inherits. An inherited parameter redefined with
+// the same key is replaced and not compared. Inherited built-in flags are not compared either as the
+// parameters of the command set are matched first.
+func (cs CommandSet) checkNames(key string, pv CommandLineParameter) error {
+	if _, ok := pv.(*Argument); ok {
+		return nil
+	}
+	for _, pk := range cs.helpKeys() {
+		existing := cs.lookupParameter(pk)
+		if _, ok := existing.(*Argument); ok || pk == key || isBuiltin(existing) {
+			continue
+		}
+		tokens := cs.nameTokens(existing)
 		for _, v := range pv.GetName() {
-			// fmt.Printf("  %s  %q\n", pk, v)
-			for _, p := range pv.GetPrefix() {
-				// fmt.Printf("  %s    %q\n", pk, p)
-				prefixes += " | " + p + v
+			if token, ok := cs.usedName(pv, v, tokens); ok {
+				return fmt.Errorf("%s: %q (%s)", ErrorNameExists, token, pk)
+			}
+		}
+	}
+	return nil
+}
+
+// nameTokens returns the prefixed names of the parameter as given on the command line
+func (cs CommandSet) nameTokens(pv CommandLineParameter) map[string]bool {
+	tokens := map[string]bool{}
+	for _, v := range pv.GetName() {
+		for _, p := range cs.parameterPrefixes(pv, v) {
+			tokens[p+v] = true
+		}
+	}
+	return tokens
+}
+
+// usedName returns the prefixed form of the parameter name found in the tokens
+func (cs CommandSet) usedName(pv CommandLineParameter, name string, tokens map[string]bool) (string, bool) {
+	for _, p := range cs.parameterPrefixes(pv, name) {
+		if tokens[p+name] {
+			return p + name, true
+		}
+	}
+	return "", false
+}
+
+// yieldBuiltinNames removes the names of the parameter from the built-in flags of the command set, so an
+// app may use -h for something other than help. A built-in flag left without names is removed.
+func (cs *CommandSet) yieldBuiltinNames(pv CommandLineParameter) {
+	if _, ok := pv.(*Argument); ok || isBuiltin(pv) {
+		return
+	}
+	tokens := cs.nameTokens(pv)
+	for _, pk := range cs.parameterKeys() {
+		f, ok := cs.Parameters[pk].(*Flag)
+		if !ok || f.builtin == false {
+			continue
+		}
+		names := []string{}
+		for _, v := range f.Name {
+			if _, used := cs.usedName(f, v, tokens); !used {
+				names = append(names, v)
+			}
+		}
+		f.Name = names
+		if len(names) == 0 {
+			cs.removeParameter(pk)
+		}
+	}
+}
+
+// checkMulticsNames reports names that would be ambiguous in Multics mode. With POSIX groups allowed,
+// word names that begin with a short name are ambiguous with a group or an option value attached to the
+// short name. i.e.: -host and -h -o -s -t or -h ost. Word names that only differ by hyphen or underscore
+// separators are duplicates. The built-in help and version flags are left out so an app may define -host
+// along with -h for help.
+func (cs CommandSet) checkMulticsNames(key string, name []string) error {
+	if cs.isMultics() == false {
+		return nil
+	}
+	posix := cs.AllowPosixGroups
+
+	for pk, pv := range cs.Parameters {
+		if pk == key || isBuiltin(pv) {
+			continue
+		}
+		for _, existing := range pv.GetName() {
+			for _, v := range name {
+				switch {
+				case len(v) > 1 && len(existing) > 1:
+					if multicsName(v) == multicsName(existing) {
+						return fmt.Errorf("%s: %q and %q (%s)", ErrorMulticsDuplicate, v, existing, pk)
+					}
+				case posix && len(v) > 1 && len(existing) == 1:
+					if strings.HasPrefix(v, existing) {
+						return fmt.Errorf("%s: %q and %q (%s)", ErrorMulticsAmbiguous, v, existing, pk)
+					}
+				case posix && len(v) == 1 && len(existing) > 1:
+					if strings.HasPrefix(existing, v) {
+						return fmt.Errorf("%s: %q and %q (%s)", ErrorMulticsAmbiguous, v, existing, pk)
+					}
+				}
 			}
 		}
-		help += fmt.Sprintf("  %-20s  %s\n", prefixes[3:], pv.GetHelp()+" ("+pk+")")
 	}
-	return help
+
+	return nil
+}
+
+// GetCommand returns the subcommand by name or nil if not defined
+func (cs *CommandSet) GetCommand(name string) *CommandSet {
+	return cs.Commands[name]
 }
 
 // MatchCommandLine helps process matches for each command set
-func (cs CommandSet) MatchCommandLine(args []matchItem) bool {
+func (cs CommandSet) MatchCommandLine(args []matchItem) error {
+	_, err := cs.matchCommandLine(args, nil)
+	return err
+}
+
+// matchCommandLine processes matches for the command set. When a subcommand name is found the remaining
+// arguments are matched by the subcommand and the selected subcommand is returned. Returns nil if no
+// subcommand was selected. Every parse error found is collected and returned as a *ParseError, or as
+// ParseErrors if there is more than one. Trace logging explains each match.
+func (cs CommandSet) matchCommandLine(args []matchItem, l *logger) (*CommandSet, error) {
+	var errs ParseErrors
+	fail := func(err error) {
+		errs = errs.append(err)
+	}
+	endOfOptions := false
+	positionals := []matchItem{}
+
+	if cs.IsRuneImp && cs.AllowPosixGroups {
+		return nil, fmt.Errorf("%w: command set %q", ErrRuneImpPosixGroups, cs.Name)
+	}
+
+	for i := 0; i < len(args); i++ {
+		cl := args[i]
+		if cl.Matched {
+			continue
+		}
+		if endOfOptions {
+			l.logf(LogTrace, "%0.3d  %q  positional after end of options", cl.Index, cl.Value)
+			positionals = append(positionals, cl)
+			continue
+		}
+		if (cs.IsGNU || cs.IsRuneImp) && cl.Value == OptionTerminator {
+			l.logf(LogTrace, "%0.3d  %q  end of options, remaining arguments are not parameters", cl.Index, cl.Value)
+			args[i].Matched = true
+			endOfOptions = true
+			continue
+		}
+		if sub, ok := cs.Commands[cl.Value]; ok {
+			l.logf(LogTrace, "%0.3d  %q  matched command %q, remaining arguments belong to it", cl.Index, cl.Value, sub.Name)
+			args[i].Matched = true
+			fail(cs.assignArguments(positionals, args, l))
+			selected, err := sub.matchCommandLine(args[i+1:], l)
+			if selected == nil {
+				selected = sub
+			}
+			switch err.(type) {
+			case nil, *ParseError, ParseErrors:
+				fail(err)
+			default:
+				return selected, err
+			}
+			return selected, errs.err()
+		}
+
+		pk, pv, value, inline := cs.matchParameter(cl.Value)
+		if pv == nil && cs.AllowPosixGroups && isPosixGroup(cl.Value) {
+			l.logf(LogTrace, "%0.3d  %q  no parameter name matched, expanding as a POSIX group", cl.Index, cl.Value)
+			next, err := cs.matchGroup(args, i, PosixPrefix, l)
+			if err != nil {
+				l.logf(LogTrace, "%0.3d  %q  POSIX group not matched: %s", cl.Index, cl.Value, err)
+				fail(err)
+				continue
+			}
+			i = next
+			continue
+		}
+		if pv == nil && cs.IsRuneImp && isRuneImpGroup(cl.Value) {
+			l.logf(LogTrace, "%0.3d  %q  no parameter name matched, expanding as a RuneImp group", cl.Index, cl.Value)
+			next, err := cs.matchGroup(args, i, RuneImpGroupPrefix, l)
+			if err != nil {
+				l.logf(LogTrace, "%0.3d  %q  RuneImp group not matched: %s", cl.Index, cl.Value, err)
+				fail(err)
+				continue
+			}
+			i = next
+			continue
+		}
+		if pv == nil && cs.looksLikeParameter(cl.Value) {
+			l.logf(LogTrace, "%0.3d  %q  no parameter, group or command matched in command set %q", cl.Index, cl.Value, cs.Name)
+			fail(&ParseError{Err: ErrUnknownParameter, Index: cl.Index, Value: cl.Value})
+			continue
+		}
+		if pv == nil {
+			l.logf(LogTrace, "%0.3d  %q  positional", cl.Index, cl.Value)
+			positionals = append(positionals, cl)
+			continue
+		}
+
+		l.logf(LogTrace, "%0.3d  %q  matched %T %q", cl.Index, cl.Value, pv, pk)
+		args[i].Matched = true
+		setIndex(pv, cl.Index)
+		switch t := pv.(type) {
+		case *Flag:
+			if inline {
+				pv.SetValue(value)
+			} else {
+				pv.SetFlag()
+			}
+		case *Option:
+			if inline {
+				l.logf(LogTrace, "%0.3d  %q  value %q given inline", cl.Index, cl.Value, value)
+				pv.SetValue(value)
+				if len(value) == 0 && t.valueRequired {
+					fail(&ParseError{Err: ErrMissingValue, Index: cl.Index, Key: pk, Value: cl.Value})
+				}
+			} else if t.valueRequired == false {
+				l.logf(LogTrace, "%0.3d  %q  value for %q is optional and not given inline", cl.Index, cl.Value, pk)
+				pv.SetValue("")
+			} else if i+1 < len(args) && args[i+1].Matched == false {
+				i++
+				l.logf(LogTrace, "%0.3d  %q  value for %q taken from the next argument", args[i].Index, args[i].Value, pk)
+				args[i].Matched = true
+				pv.SetValue(args[i].Value)
+			} else {
+				fail(&ParseError{Err: ErrMissingValue, Index: cl.Index, Key: pk, Value: cl.Value})
+			}
+		default:
+			l.logf(LogTrace, "%0.3d  %q  unhandled parameter type %T", cl.Index, cl.Value, t)
+		}
+	}
+	fail(cs.assignArguments(positionals, args, l))
 
 	for i, cl := range args {
-	ArgsContinue:
-		for pk, pv := range cs.Parameters {
-			for _, v := range pv.GetName() {
-				for _, p := range pv.GetPrefix() {
-					// NOTE: Need better testing. Need to check for --option=value with strings.StartsWith, etc.
-					if cl.Value == p+v {
-						fmt.Printf("%s | %s | %s%s | ***MATCH***\n", cl.Value, pk, p, v)
-						args[i].Matched = true
-						switch t := pv.(type) {
-						case *Flag:
-							pv.SetFlag()
-							// cs.Parameters[pk].SetFlag()
-						case *Argument:
-							// NOTE: Fixed position or trailing?
-							pv.SetValue(cl.Value)
-						case *Option:
-							// NOTE: Required value? How should I handle the option value testing?
-							pv.SetValue(cl.Value)
-						default:
-							log.Printf("type = %T\n", t)
-						}
-						break ArgsContinue
-					} else {
-						fmt.Printf("%s | %s | %s%s\n", cl.Value, pk, p, v)
+		l.logf(LogDebug, "Args | %02d | %#v", i, cl)
+	}
+
+	return nil, errs.err()
+}
+
+// reset clears the parameter values found by a previous parse for the command set and its subcommands
+func (cs *CommandSet) reset() {
+	for _, pv := range cs.Parameters {
+		resetParameter(pv)
+	}
+	for _, sub := range cs.Commands {
+		sub.reset()
+	}
+}
+
+// checkRequired returns an error for every required parameter of the command set or its parents not given
+// on the command line or by an environment variable or config file default
+func (cs *CommandSet) checkRequired() error {
+	var errs ParseErrors
+	for set := cs; set != nil; set = set.parent {
+		for _, pk := range set.parameterKeys() {
+			pv := set.Parameters[pk]
+			// Parameters found without their value are reported by the matcher
+			if isRequired(pv) && isSupplied(pv) == false && parameterIndex(pv) == 0 {
+				errs = append(errs, &ParseError{Err: ErrMissingParameter, Key: pk})
+			}
+		}
+	}
+	return errs.err()
+}
+
+// allParameters returns the parameters of the command set and those inherited from its parents
+func (cs CommandSet) allParameters() map[string]CommandLineParameter {
+	inherited, _ := cs.inheritedParameters()
+	if len(inherited) == 0 {
+		return cs.Parameters
+	}
+	for pk, pv := range cs.Parameters {
+		inherited[pk] = pv
+	}
+	return inherited
+}
+
+// inheritedParameters returns the persistent parameters of all parent command sets not redefined by this command set
+// along with their keys in parent definition order
+func (cs CommandSet) inheritedParameters() (map[string]CommandLineParameter, []string) {
+	inherited := map[string]CommandLineParameter{}
+	keys := []string{}
+	for parent := cs.parent; parent != nil; parent = parent.parent {
+		for _, pk := range parent.Persistent {
+			pv, ok := parent.Parameters[pk]
+			if !ok {
+				continue
+			}
+			if _, ok := cs.Parameters[pk]; ok {
+				continue
+			}
+			if _, ok := inherited[pk]; ok {
+				continue
+			}
+			inherited[pk] = pv
+			keys = append(keys, pk)
+		}
+	}
+	return inherited, keys
+}
+
+// matchParameter finds the parameter named by a command line token. If the token is a GNU style
+// --name=value the value portion is returned with inline set to true. Parameters are checked in
+// definition order, those of the command set before inherited ones.
+func (cs CommandSet) matchParameter(token string) (key string, param CommandLineParameter, value string, inline bool) {
+	for _, pk := range cs.helpKeys() {
+		pv := cs.lookupParameter(pk)
+		if _, ok := pv.(*Argument); ok {
+			continue
+		}
+		for _, v := range pv.GetName() {
+			for _, p := range cs.parameterPrefixes(pv, v) {
+				name := p + v
+				if token == name {
+					return pk, pv, "", false
+				}
+				if cs.IsGNU && strings.HasPrefix(token, name+GNUValueSeparator) {
+					return pk, pv, token[len(name)+len(GNUValueSeparator):], true
+				}
+				if cs.isMultics() && len(v) > 1 && p == PosixPrefix && strings.HasPrefix(token, p) {
+					if multicsName(token[len(p):]) == multicsName(v) {
+						return pk, pv, "", false
 					}
 				}
 			}
 		}
 	}
+	return "", nil, "", false
+}
+
+// matchGroup expands a POSIX group such as -abc, or a RuneImp group such as --abc, into its individual
+// single letter parameters. If a letter names an Option the remainder of the group, or the next argument,
+// is its value. Returns the index of the last argument consumed.
+func (cs CommandSet) matchGroup(args []matchItem, i int, groupPrefix string, l *logger) (int, error) {
+	token := args[i].Value
+	index := args[i].Index
+	letters := []rune(strings.TrimPrefix(token, groupPrefix))
+	params := []CommandLineParameter{}
+	value := ""
+	next := i
+
+	for j, r := range letters {
+		pk, pv, _, _ := cs.matchParameter(PosixPrefix + string(r))
+		if pv == nil {
+			return i, &ParseError{Err: ErrUnknownParameter, Index: index, Value: token, Cause: fmt.Errorf("%q", r)}
+		}
+		l.logf(LogTrace, "%0.3d  %q  letter %q matched %T %q", index, token, r, pv, pk)
+		params = append(params, pv)
+		if o, ok := pv.(*Option); ok {
+			value = string(letters[j+1:])
+			if len(value) == 0 && o.valueRequired {
+				if i+1 >= len(args) || args[i+1].Matched {
+					return i, &ParseError{Err: ErrMissingValue, Index: index, Key: pk, Value: token}
+				}
+				next = i + 1
+				value = args[next].Value
+			}
+			break
+		}
+	}
 
-	for i, cl := range args {
-		log.Printf("Args | %02d | %#v\n", i, cl)
+	for _, pv := range params {
+		setIndex(pv, index)
+		switch pv.(type) {
+		case *Option:
+			pv.SetValue(value)
+		default:
+			pv.SetFlag()
+		}
+	}
+	for j := i; j <= next; j++ {
+		args[j].Matched = true
 	}
 
-	return false
+	return next, nil
+}
+
+// isMultics returns true if Multics word names are in use. The RuneImp convention is a superset of Multics.
+func (cs CommandSet) isMultics() bool {
+	return cs.IsMultics || cs.IsRuneImp
+}
+
+// parameterPrefixes returns the prefixes valid for the given parameter name.
+// GNU mode adds the double hyphen prefix to word based names.
+func (cs CommandSet) parameterPrefixes(pv CommandLineParameter, name string) []string {
+	prefixes := pv.GetPrefix()
+	if cs.IsGNU && len(name) > 1 && containsString(prefixes, GNUPrefix) == false {
+		prefixes = append(append([]string{}, prefixes...), GNUPrefix)
+	}
+	return prefixes
 }
 
 // SetGNU defines if parameter can use GNU long option names.
@@ -216,10 +761,27 @@ func (cs *CommandSet) SetGNU(v bool) {
 
 // SetMultics defines if the parameter can use Multics option names.
 // One or more letter or word names with a single hyphen prefix and potential hyphen or underscore based word separation.
+// i.e.: -long_name and -long-name are equivalent. Should be set before parameters are added so
+// ambiguous names can be reported when they are defined.
 func (cs *CommandSet) SetMultics(v bool) {
 	cs.IsMultics = v
 }
 
+// SetPersistent defines if the parameter is inherited by the subcommands of the command set
+func (cs *CommandSet) SetPersistent(key string, v bool) {
+	for i, pk := range cs.Persistent {
+		if pk == key {
+			if v == false {
+				cs.Persistent = append(cs.Persistent[:i], cs.Persistent[i+1:]...)
+			}
+			return
+		}
+	}
+	if v {
+		cs.Persistent = append(cs.Persistent, key)
+	}
+}
+
 // SetPosix defines if the parameter can use POSIX short option names.
 // A single letter name with a single hyphen prefix.
 func (cs *CommandSet) SetPosix(v bool) {
@@ -227,22 +789,39 @@ func (cs *CommandSet) SetPosix(v bool) {
 }
 
 // SetPosixGroups defines if POSIX group processing is done. i.e.: a group of letters prefixed with a single hyphen are expanded into individual POSIX options.
-// Should not be combined with Multics options.
-func (cs *CommandSet) SetPosixGroups(v bool) {
+// Should not be combined with Multics options. Returns ErrRuneImpPosixGroups if RuneImp grouping is enabled.
+func (cs *CommandSet) SetPosixGroups(v bool) error {
+	if v && cs.IsRuneImp {
+		return fmt.Errorf("%w: command set %q", ErrRuneImpPosixGroups, cs.Name)
+	}
 	cs.AllowPosixGroups = v
+	return nil
 }
 
 // SetRuneImp defines if the parameter can use RuneImp option names.
 // Multics plus Grouping of multiple single letter names prefixed with a double hyphen and no name separation.
-// Can not be combined with POSIX groups.
-func (cs *CommandSet) SetRuneImp(v bool) {
+// i.e.: --abc is expanded into -a -b -c. Can not be combined with POSIX groups, returning ErrRuneImpPosixGroups.
+func (cs *CommandSet) SetRuneImp(v bool) error {
+	if v && cs.AllowPosixGroups {
+		return fmt.Errorf("%w: command set %q", ErrRuneImpPosixGroups, cs.Name)
+	}
 	cs.IsRuneImp = v
+	return nil
 }
 
 // Argument is the data type for command line arguments
 type Argument struct {
 	Parameter
-	defaultValue string // The default value to use if one is not given on the command line
+	arity        int            // The number of values taken. Default: 1. See ArityOptional and ArityVariadic.
+	completion   CompletionFunc // Lists the value candidates for shell completion
+	defaultValue string         // The default value to use if one is not given on the command line
+	hasDefault   bool           // If a default value was defined
+	typed        Value          // Converts and stores the value at parse time
+}
+
+// GetEnv returns the environment variable names, without the command set prefix, checked for a default value
+func (a *Argument) GetEnv() []string {
+	return a.Parameter.envNames
 }
 
 // GetFlag returns the current boolean value for the parameter
@@ -262,18 +841,18 @@ func (a *Argument) GetHelp() string {
 // GetInt returns the value as a system integer
 func (a *Argument) GetInt() (int, error) {
 	i, err := strconv.Atoi(a.Parameter.value)
-	return i, err
+	return i, conversionError(&a.Parameter, err)
 }
 
-// GetName returns the parameters names available on the command line
+// GetName returns the display names of the argument. Arguments are matched by position, not by name.
 func (a *Argument) GetName() []string {
-	return []string{}
+	return a.Parameter.Name
 }
 
 // GetNumber returns the value as a float64
 func (a *Argument) GetNumber() (float64, error) {
 	i, err := strconv.ParseFloat(a.Parameter.value, 64)
-	return i, err
+	return i, conversionError(&a.Parameter, err)
 }
 
 // GetPrefix returns the valid values for to prefix the parameter names
@@ -285,33 +864,57 @@ func (a *Argument) GetPrefix() []string {
 func (a *Argument) GetUint() (uint, error) {
 	i, err := strconv.ParseUint(a.Parameter.value, 10, intSize)
 	// ui := uint(i)
-	return uint(i), err
+	return uint(i), conversionError(&a.Parameter, err)
+}
+
+// GetValues returns all values given for the argument
+func (a *Argument) GetValues() []string {
+	return a.values
 }
 
 // GetValue returns the current value for the parameter
 func (a *Argument) GetValue() (string, error) {
 	if a.valueSet == false {
 		if a.defaultSet {
-			if a.configPreferred && len(a.configDefault) > 0 {
-				a.value = a.configDefault
-			} else if len(a.envDefault) > 0 {
-				a.value = a.envDefault
-			} else {
-				a.value = a.configDefault
+			if v := a.externalDefault(); len(v) > 0 {
+				return v, nil
 			}
-		} else {
+		} else if a.hasDefault == false {
 			return "", errors.New(ErrorArgumentMissing)
 		}
 	}
 	return a.value, nil
 }
 
+// SetCompletion defines the function listing the value candidates for shell completion
+func (a *Argument) SetCompletion(f CompletionFunc) {
+	a.completion = f
+}
+
+// SetConfigPreferred defines if a config file default is preferred over an environment variable default
+func (a *Argument) SetConfigPreferred(b bool) {
+	a.configPreferred = b
+}
+
 // SetDefault defines the default value to use if there is no value given on the command line and no environment or config variable default found
 func (a *Argument) SetDefault(s string) error {
+	a.defaultValue = s
+	a.hasDefault = true
 	a.value = s
 	return nil
 }
 
+// SetEnv defines the environment variable names, without the command set prefix, checked in order for a default value
+func (a *Argument) SetEnv(names []string) error {
+	for _, name := range names {
+		if len(strings.TrimSpace(name)) == 0 {
+			return errors.New(ErrorEnvDefaultSetEmpty)
+		}
+	}
+	a.envNames = names
+	return nil
+}
+
 // SetFlag is a NO-OP that will panic for this parameter type
 func (a *Argument) SetFlag() {
 	panic("SetFlag use not appropriate for this parameter type")
@@ -326,6 +929,12 @@ func (a *Argument) SetKey(s string) error {
 	return nil
 }
 
+// SetArity defines the number of values the argument takes. ArityOptional for zero or one value and
+// ArityVariadic for the rest of the positional arguments.
+func (a *Argument) SetArity(n int) {
+	a.arity = n
+}
+
 // SetName defines the parameter name(s) allowed on the command line
 func (a *Argument) SetName(s []string) error {
 	for _, v := range s {
@@ -339,6 +948,12 @@ func (a *Argument) SetName(s []string) error {
 	return nil
 }
 
+// SetPosition defines the fixed position of the argument among the positional arguments, starting at 1.
+// Zero means the argument takes the next positional arguments in definition order.
+func (a *Argument) SetPosition(n uint) {
+	a.Position = n
+}
+
 // SetPrefix allows for alternate or custom prefixes to be used. Default prefix is a hyphen.
 func (a *Argument) SetPrefix(list []string, appendToList bool) {
 	if appendToList {
@@ -355,17 +970,39 @@ func (a *Argument) SetRequired(b bool) {
 	a.IsRequired = b
 }
 
-// SetValue defines the command line value given
+// SetValueType defines the Value the parameter value is passed to at parse time. A value the Value fails to
+// set is reported as a *ParseError with ErrConversion. i.e.: DurationValue(&timeout)
+func (a *Argument) SetValueType(v Value) {
+	a.typed = v
+}
+
+// SetValue defines the command line value given. Arguments taking more than one value collect each value given.
 func (a *Argument) SetValue(s string) {
-	a.value = s
+	if a.valueSet == false {
+		a.value = s
+		a.values = nil
+	}
+	a.values = append(a.values, s)
 	a.valueSet = true
 }
 
 // Flag is the data type for command line flags
 type Flag struct {
 	Parameter
-	defaultValue bool // The default value to use if one is not given on the command line. Default: false
-	flagValue    bool // The actual value of the parameter given
+	builtin      bool  // If the flag is the built-in help or version flag
+	count        int   // The number of times a counter flag was given, less those of its decrementing flags
+	counter      bool  // If GetInt returns the number of times the flag was given
+	decrements   *Flag // The counter flag decremented each time this flag is given
+	defaultCount int   // The default count of a counter flag
+	defaultValue bool  // The default value to use if one is not given on the command line. Default: false
+	hasDefault   bool  // If a default value was defined
+	flagValue    bool  // The actual value of the parameter given
+	typed        Value // Stores the value at parse time. Set by Bind.
+}
+
+// GetEnv returns the environment variable names, without the command set prefix, checked for a default value
+func (f *Flag) GetEnv() []string {
+	return f.Parameter.envNames
 }
 
 // GetFlag returns the current boolean value for the parameter
@@ -380,6 +1017,12 @@ func (f *Flag) GetHelp() string {
 
 // GetInt returns the value as a system integer
 func (f *Flag) GetInt() (int, error) {
+	if f.counter {
+		if f.valueSet {
+			return f.count, nil
+		}
+		return f.counterBase() + f.count, nil
+	}
 	if f.flagValue {
 		return 1, nil
 	}
@@ -393,6 +1036,10 @@ func (f *Flag) GetName() []string {
 
 // GetNumber returns the value as a float64
 func (f *Flag) GetNumber() (float64, error) {
+	if f.counter {
+		n, err := f.GetInt()
+		return float64(n), err
+	}
 	if f.flagValue {
 		return 1.0, nil
 	}
@@ -406,6 +1053,13 @@ func (f *Flag) GetPrefix() []string {
 
 // GetUint returns the value as a system unsigned integer
 func (f *Flag) GetUint() (uint, error) {
+	if f.counter {
+		n, err := f.GetInt()
+		if n < 0 {
+			n = 0
+		}
+		return uint(n), err
+	}
 	if f.flagValue {
 		return 1, nil
 	}
@@ -414,40 +1068,54 @@ func (f *Flag) GetUint() (uint, error) {
 
 // GetValue returns the current value for the parameter
 func (f *Flag) GetValue() (string, error) {
-	// if f.valueSet == false {
-	// 	if f.defaultSet {
-	// 		if f.configPreferred && len(f.configDefault) > 0 {
-	// 			if truthyString(f.configDefault) {
-	// 				f.flagValue = true
-	// 			}
-	// 		} else if len(f.envDefault) > 0 {
-	// 			f.flagValue = truthyString(f.envDefault)
-	// 		} else {
-	// 			f.flagValue = truthyString(f.configDefault)
-	// 		}
-	// 	}
-	// }
-	if f.flagValue {
-		return "true", nil
+	if f.counter {
+		n, err := f.GetInt()
+		return strconv.Itoa(n), err
+	}
+	b := f.flagValue
+	if f.valueSet == false && f.defaultSet {
+		if v := f.externalDefault(); len(v) > 0 {
+			b = truthyString(v)
+		}
 	}
-	return "false", nil
+	return strconv.FormatBool(b), nil
+}
+
+// SetConfigPreferred defines if a config file default is preferred over an environment variable default
+func (f *Flag) SetConfigPreferred(b bool) {
+	f.configPreferred = b
 }
 
 // SetDefault defines the default value to use if there is no value given on the command line and no environment or config variable default found
 func (f *Flag) SetDefault(s string) error {
+	f.defaultCount = countString(s)
+	f.defaultValue = truthyString(s)
+	f.hasDefault = true
 	f.value = s
-	f.flagValue = truthyString(s)
+	f.flagValue = f.defaultValue
+	return nil
+}
+
+// SetEnv defines the environment variable names, without the command set prefix, checked in order for a default value
+func (f *Flag) SetEnv(names []string) error {
+	for _, name := range names {
+		if len(strings.TrimSpace(name)) == 0 {
+			return errors.New(ErrorEnvDefaultSetEmpty)
+		}
+	}
+	f.envNames = names
 	return nil
 }
 
 // SetFlag defines the command line flag as set
 func (f *Flag) SetFlag() {
-	log.Printf("Flag.SetFlag() | true\n")
 	f.flagValue = true
 	f.Parameter.value = "true"
 	f.Parameter.valueSet = true
-	log.Printf("Flag.SetFlag() | f.flagValue = %t\n", f.flagValue)
-	log.Printf("Flag.SetFlag() | f.GetFlag() = %t\n", f.GetFlag())
+	f.count++
+	if f.decrements != nil {
+		f.decrements.count--
+	}
 }
 
 // SetKey defines the key name to reference in code
@@ -488,18 +1156,38 @@ func (f *Flag) SetRequired(b bool) {
 	f.IsRequired = b
 }
 
-// SetValue defines the command line value given
+// SetValue defines the command line value given. A counter flag takes the value as its count and a
+// decrementing flag decrements its counter by the value. i.e.: --verbose=3
 func (f *Flag) SetValue(s string) {
-	log.Printf("Flag.SetValue() | %q\n", s)
 	f.flagValue = truthyString(s)
 	f.value = s
 	f.valueSet = true
+	if f.counter {
+		f.count = countString(s)
+		f.flagValue = f.count > 0
+	}
+	if f.decrements != nil {
+		f.decrements.count -= countString(s)
+	}
 }
 
 // Option is the data type for command line options
 type Option struct {
 	Parameter
-	defaultValue string // The default value to use if one is not given on the command line
+	completion   CompletionFunc // Lists the value candidates for shell completion
+	defaultValue string         // The default value to use if one is not given on the command line
+	hasDefault   bool           // If a default value was defined
+	isMap        bool           // If the values are key=value entries
+	maxCount     int            // The maximum number of values of a repeatable option. Zero is unlimited.
+	minCount     int            // The minimum number of values of a repeatable option
+	repeatable   bool           // If each occurrence adds to the values
+	separator    string         // Splits each value of a repeatable option into several values
+	typed        Value          // Converts and stores the value at parse time
+}
+
+// GetEnv returns the environment variable names, without the command set prefix, checked for a default value
+func (o *Option) GetEnv() []string {
+	return o.Parameter.envNames
 }
 
 // GetFlag returns the current boolean value for the parameter
@@ -519,7 +1207,7 @@ func (o *Option) GetHelp() string {
 // GetInt returns the value as a system integer
 func (o *Option) GetInt() (int, error) {
 	i, err := strconv.Atoi(o.Parameter.value)
-	return i, err
+	return i, conversionError(&o.Parameter, err)
 }
 
 // GetName returns the parameters names available on the command line
@@ -530,7 +1218,7 @@ func (o *Option) GetName() []string {
 // GetNumber returns the value as a float64
 func (o *Option) GetNumber() (float64, error) {
 	i, err := strconv.ParseFloat(o.Parameter.value, 64)
-	return i, err
+	return i, conversionError(&o.Parameter, err)
 }
 
 // GetPrefix returns the valid values for to prefix the parameter names
@@ -542,33 +1230,52 @@ func (o *Option) GetPrefix() []string {
 func (o *Option) GetUint() (uint, error) {
 	i, err := strconv.ParseUint(o.Parameter.value, 10, intSize)
 	// ui := uint(i)
-	return uint(i), err
+	return uint(i), conversionError(&o.Parameter, err)
 }
 
 // GetValue returns the current value for the parameter
 func (o *Option) GetValue() (string, error) {
 	if o.valueSet == false {
 		if o.defaultSet {
-			if o.configPreferred && len(o.configDefault) > 0 {
-				o.value = o.configDefault
-			} else if len(o.envDefault) > 0 {
-				o.value = o.envDefault
-			} else {
-				o.value = o.configDefault
+			if v := o.externalDefault(); len(v) > 0 {
+				return v, nil
 			}
-		} else {
+		} else if o.hasDefault == false {
 			return "", errors.New(ErrorOptionMissing)
 		}
 	}
 	return o.value, nil
 }
 
+// SetCompletion defines the function listing the value candidates for shell completion
+func (o *Option) SetCompletion(f CompletionFunc) {
+	o.completion = f
+}
+
+// SetConfigPreferred defines if a config file default is preferred over an environment variable default
+func (o *Option) SetConfigPreferred(b bool) {
+	o.configPreferred = b
+}
+
 // SetDefault defines the default value to use if there is no value given on the command line and no environment or config variable default found
 func (o *Option) SetDefault(s string) error {
+	o.defaultValue = s
+	o.hasDefault = true
 	o.value = s
 	return nil
 }
 
+// SetEnv defines the environment variable names, without the command set prefix, checked in order for a default value
+func (o *Option) SetEnv(names []string) error {
+	for _, name := range names {
+		if len(strings.TrimSpace(name)) == 0 {
+			return errors.New(ErrorEnvDefaultSetEmpty)
+		}
+	}
+	o.envNames = names
+	return nil
+}
+
 // SetFlag is a NO-OP that will panic for this parameter type
 func (o *Option) SetFlag() {
 	panic("SetFlag use not appropriate for this parameter type")
@@ -606,8 +1313,20 @@ func (o *Option) SetName(s []string) error {
 	return nil
 }
 
+// SetValueType defines the Value the parameter value is passed to at parse time. A value the Value fails to
+// set is reported as a *ParseError with ErrConversion. i.e.: DurationValue(&timeout)
+func (o *Option) SetValueType(v Value) {
+	o.typed = v
+}
+
 // SetValue defines the command line value given
 func (o *Option) SetValue(s string) {
+	if o.repeatable {
+		if o.valueSet == false {
+			o.values = nil
+		}
+		o.values = append(o.values, o.splitValue(s)...)
+	}
 	o.value = s
 	o.valueSet = true
 }
@@ -617,6 +1336,12 @@ func (o *Option) SetRequired(b bool) {
 	o.IsRequired = b
 }
 
+// SetValueRequired defines if the option's value is required. An option with an optional value only takes
+// a value given inline. i.e.: --color=always
+func (o *Option) SetValueRequired(b bool) {
+	o.valueRequired = b
+}
+
 // Parameter is the data type for all options and arguments
 type Parameter struct {
 	configDefault   string   // Configuration variable to use as a default value if the parameter is not present on the command line
@@ -624,6 +1349,7 @@ type Parameter struct {
 	defaultSet      bool     // If there is a default value to check for
 	Description     string   // The long description to display to the user
 	envDefault      string   // Environment variable to use as a default value if the parameter is not present on the command line
+	envNames        []string // Environment variable names, without the command set prefix, checked in order for a default value
 	help            string   // The help information to display to the user
 	Index           int      // The actual index on the command line. Default: 0 (equals not set as the zeroth position is the command itself)
 	IsRequired      bool     // Defines if this parameter is required on the command line
@@ -634,27 +1360,119 @@ type Parameter struct {
 	Suffix          []string // List of allowed parameter suffixes. Mostly used for arguments. Though occasionally used for options/flags.
 	Summery         string   // The short description to display to the user
 	value           string   // The actual value of the parameter given
+	values          []string // All values given for parameters taking more than one value
 	valueRequired   bool     // Defines if this parameter's value is required
 	valueSet        bool     // The flag was set or actual value of the parameter was given
 }
 
-/*
- * VARIABLES
- */
-var (
-	matchList = []matchItem{}
-)
+// externalDefault returns the config file or environment variable default of the parameter. The
+// environment variable is preferred unless configPreferred is set.
+func (p *Parameter) externalDefault() string {
+	if p.configPreferred && len(p.configDefault) > 0 {
+		return p.configDefault
+	}
+	if len(p.envDefault) > 0 {
+		return p.envDefault
+	}
+	return p.configDefault
+}
+
+// ParseError is the error type for command line parsing failures
+type ParseError struct {
+	Cause error  // The underlying error if any. i.e.: a strconv error for conversion failures.
+	Err   error  // The kind of failure. One of the Err* values such as ErrUnknownParameter.
+	Index int    // The index of the argument on the command line. Default: 0 (not related to a specific argument)
+	Key   string // The key of the parameter. Empty if the argument did not match a parameter.
+	Value string // The command line argument or parameter value
+}
+
+// Error returns the error message
+func (e *ParseError) Error() string {
+	msg := e.Err.Error()
+	if len(e.Key) > 0 {
+		msg = fmt.Sprintf("%s: %s", e.Key, msg)
+	}
+	if e.Index > 0 {
+		msg = fmt.Sprintf("argument %d %q: %s", e.Index, e.Value, msg)
+	} else if len(e.Value) > 0 {
+		msg = fmt.Sprintf("%s: %q", msg, e.Value)
+	}
+	if e.Cause != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Cause)
+	}
+	return msg
+}
+
+// Unwrap returns the kind of failure so errors.Is can be used with ErrConversion, ErrMissingParameter, etc.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is the error type for more than one command line parsing failure
+type ParseErrors []*ParseError
+
+// Error returns the error messages, one per line
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is returns true if any of the errors is the target. i.e.: errors.Is(err, ErrMissingParameter)
+func (e ParseErrors) Is(target error) bool {
+	for _, pe := range e {
+		if errors.Is(pe, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets the target to the first error if the target is a **ParseError
+func (e ParseErrors) As(target interface{}) bool {
+	if t, ok := target.(**ParseError); ok && len(e) > 0 {
+		*t = e[0]
+		return true
+	}
+	return false
+}
+
+// append adds a *ParseError or the contents of a ParseErrors to the list. Other errors are ignored.
+func (e ParseErrors) append(err error) ParseErrors {
+	switch t := err.(type) {
+	case *ParseError:
+		if t != nil {
+			e = append(e, t)
+		}
+	case ParseErrors:
+		e = append(e, t...)
+	}
+	return e
+}
+
+// err returns nil for no errors, the *ParseError for one error and the ParseErrors otherwise
+func (e ParseErrors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
 
 /*
  * FUNCTIONS
  */
 
-// New returns a CLIOPATra singleton instance
+// New returns a new CLIOPATra instance. Each instance is independent of any other. Returns
+// ErrRuneImpPosixGroups if the command set enables both RuneImp grouping and POSIX groups.
 func New(cs CommandSet) (*Cliopatra, error) {
-	if cliopatraInstance != nil {
-		return cliopatraInstance, nil
+	if cs.IsRuneImp && cs.AllowPosixGroups {
+		return nil, fmt.Errorf("%w: command set %q", ErrRuneImpPosixGroups, cs.Name)
 	}
-
 	cs.Parameters = make(map[string]CommandLineParameter)
 	if len(cs.Prefix) == 0 && len(DefaultPrefix) > 0 {
 		cs.Prefix = []string{DefaultPrefix}
@@ -663,9 +1481,150 @@ func New(cs CommandSet) (*Cliopatra, error) {
 		cs.Suffix = []string{DefaultSuffix}
 	}
 
-	cliopatraInstance := &Cliopatra{CommandSet: &cs}
+	c := &Cliopatra{
+		CommandSet: &cs,
+		exit:       os.Exit,
+		output:     os.Stdout,
+	}
+	f, err := c.setBuiltin(HelpKey, nil, DefaultHelpNames, "Show this help and exit")
+	if err != nil {
+		return nil, err
+	}
+	c.helpFlag = f
+
+	return c, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isPosixGroup returns true if the token may be a group of single letter names prefixed with a single hyphen.
+// Negative numbers are positional arguments.
+func isPosixGroup(token string) bool {
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return false
+	}
+	return len(token) > len(PosixPrefix)+1 && strings.HasPrefix(token, PosixPrefix) && strings.HasPrefix(token, GNUPrefix) == false
+}
+
+// isRuneImpGroup returns true if the token may be a group of single letter names prefixed with a double hyphen
+func isRuneImpGroup(token string) bool {
+	return len(token) > len(RuneImpGroupPrefix)+1 && strings.HasPrefix(token, RuneImpGroupPrefix)
+}
+
+// multicsName returns the name with hyphen word separators replaced by underscores so either separator matches
+func multicsName(s string) string {
+	return strings.ReplaceAll(s, "-", MulticsSeparator)
+}
+
+// conversionError returns a ParseError for a failed value conversion of the parameter
+func conversionError(p *Parameter, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ParseError{Cause: err, Err: ErrConversion, Index: p.Index, Key: p.Key, Value: p.value}
+}
+
+// parameterOf returns the common parameter data of the parameter
+func parameterOf(pv CommandLineParameter) *Parameter {
+	switch t := pv.(type) {
+	case *Argument:
+		return &t.Parameter
+	case *Flag:
+		return &t.Parameter
+	case *Option:
+		return &t.Parameter
+	}
+	return &Parameter{}
+}
+
+// parameterIndex returns the command line index where the parameter was found. Zero if not found.
+func parameterIndex(pv CommandLineParameter) int {
+	return parameterOf(pv).Index
+}
+
+// isRequired returns true if the parameter is required on the command line
+func isRequired(pv CommandLineParameter) bool {
+	return parameterOf(pv).IsRequired
+}
+
+// isSupplied returns true if the parameter was given on the command line or by an environment variable or config file default
+func isSupplied(pv CommandLineParameter) bool {
+	p := parameterOf(pv)
+	return p.valueSet || p.defaultSet
+}
+
+// isSet returns true if the parameter was given on the command line
+func isSet(pv CommandLineParameter) bool {
+	return parameterOf(pv).valueSet
+}
+
+// resetParameter restores the parameter to its state before any command line values were given
+func resetParameter(pv CommandLineParameter) {
+	switch t := pv.(type) {
+	case *Argument:
+		t.Index = 0
+		t.configDefault = ""
+		t.defaultSet = false
+		t.envDefault = ""
+		t.value = t.defaultValue
+		t.values = nil
+		t.valueSet = false
+		if r, ok := t.typed.(interface{ reset() }); ok {
+			r.reset()
+		}
+	case *Flag:
+		t.Index = 0
+		t.configDefault = ""
+		t.defaultSet = false
+		t.envDefault = ""
+		t.count = 0
+		t.flagValue = t.defaultValue
+		t.value = strconv.FormatBool(t.defaultValue)
+		t.valueSet = false
+		if r, ok := t.typed.(interface{ reset() }); ok {
+			r.reset()
+		}
+	case *Option:
+		t.Index = 0
+		t.configDefault = ""
+		t.defaultSet = false
+		t.envDefault = ""
+		t.value = t.defaultValue
+		t.values = nil
+		t.valueSet = false
+		if r, ok := t.typed.(interface{ reset() }); ok {
+			r.reset()
+		}
+	}
+}
+
+// resolveDefault stores the config file or environment variable default as the value of a parameter not
+// given on the command line. Done while parsing so the getters only read the parameter.
+func resolveDefault(pv CommandLineParameter) {
+	p := parameterOf(pv)
+	if p.valueSet || p.defaultSet == false {
+		return
+	}
+	v := p.externalDefault()
+	if len(v) == 0 {
+		return
+	}
+	p.value = v
+	if f, ok := pv.(*Flag); ok {
+		f.flagValue = truthyString(v)
+	}
+}
 
-	return cliopatraInstance, nil
+// setIndex records the command line index where the parameter was found
+func setIndex(pv CommandLineParameter, index int) {
+	parameterOf(pv).Index = index
 }
 
 func truthyString(s string) bool {
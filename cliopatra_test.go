@@ -0,0 +1,503 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentInstances parses with an instance per goroutine, which must not share any state
+func TestConcurrentInstances(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			o, _ := c.AddOption("name", []string{"n", "name"}, nil, "The name")
+			for j := 0; j < 50; j++ {
+				want := fmt.Sprintf("%d-%d", i, j)
+				if err := c.Parse([]string{"--name", want}); err != nil {
+					t.Error(err)
+					return
+				}
+				if got, _ := o.GetValue(); got != want {
+					t.Errorf("GetValue() = %q, want %q", got, want)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentRun calls Parse on a shared instance while Run handlers read the parsed values. The
+// instance stays locked while a handler runs so the handler always sees the values of its own parse.
+func TestConcurrentRun(t *testing.T) {
+	c, err := New(CommandSet{Name: "app", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := c.AddOption("name", []string{"name"}, nil, "The name")
+	o.SetEnv([]string{"NAME"})
+	c.Handler = func(cs *CommandSet) error {
+		for i := 0; i < 10; i++ {
+			if got, _ := o.GetValue(); got != "run" {
+				return fmt.Errorf("handler GetValue() = %q, want %q", got, "run")
+			}
+		}
+		return nil
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"app", "--name", "run"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if err := c.Parse([]string{"--name", fmt.Sprint(i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentReads reads env defaulted values from several goroutines once parsing is done
+func TestConcurrentReads(t *testing.T) {
+	os.Setenv("APP_NAME", "env")
+	defer os.Unsetenv("APP_NAME")
+
+	c, err := New(CommandSet{Name: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := c.AddOption("name", []string{"name"}, nil, "The name")
+	o.SetEnv([]string{"APP_NAME"})
+	f, _ := c.AddFlag("debug", []string{"debug"}, nil, "Debug")
+	f.SetEnv([]string{"APP_NAME"})
+	if err := c.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, _ := o.GetValue(); got != "env" {
+				t.Errorf("GetValue() = %q, want %q", got, "env")
+			}
+			f.GetValue()
+			f.GetFlag()
+		}()
+	}
+	wg.Wait()
+}
+
+// newTestApp returns an app with a counter flag, a flag, an option and a source argument defined
+func newTestApp(t *testing.T, cs CommandSet) *Cliopatra {
+	t.Helper()
+	c, err := New(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbose, err := c.AddFlag("verbose", []string{"v", "verbose"}, nil, "Verbose output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbose.SetCounter(true)
+	if _, err := c.AddFlag("all", []string{"a", "all"}, nil, "All files"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddOption("output", []string{"o", "output"}, nil, "The output file"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddArgument("source", []string{"source"}, "The source file"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// checkValues reports the parameter values that differ from the wanted values
+func checkValues(t *testing.T, cs *CommandSet, want map[string]string) {
+	t.Helper()
+	for pk, v := range want {
+		pv, ok := cs.Parameters[pk]
+		if !ok {
+			t.Errorf("parameter %q not defined", pk)
+			continue
+		}
+		if got, _ := pv.GetValue(); got != v {
+			t.Errorf("%s GetValue() = %q, want %q", pk, got, v)
+		}
+	}
+}
+
+// checkErrors reports if the error does not wrap each of the wanted errors
+func checkErrors(t *testing.T, err error, want []error) {
+	t.Helper()
+	if len(want) == 0 && err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, w := range want {
+		if !errors.Is(err, w) {
+			t.Errorf("error %v, want %v", err, w)
+		}
+	}
+}
+
+// parseTest is a command line and the parameter values and errors it should give
+type parseTest struct {
+	name string
+	args []string
+	want map[string]string
+	errs []error
+}
+
+// runParseTests parses each command line with a new test app for the command set
+func runParseTests(t *testing.T, cs CommandSet, tests []parseTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestApp(t, cs)
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			checkValues(t, c.CommandSet, tt.want)
+		})
+	}
+}
+
+func TestParseGNU(t *testing.T) {
+	runParseTests(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}}, []parseTest{
+		{"long", []string{"--verbose", "--output", "out.txt", "in.txt"}, map[string]string{"verbose": "1", "all": "false", "output": "out.txt", "source": "in.txt"}, nil},
+		{"inline", []string{"--output=out.txt", "--all=true"}, map[string]string{"output": "out.txt", "all": "true"}, nil},
+		{"inline empty", []string{"--all="}, map[string]string{"all": "false"}, nil},
+		{"short", []string{"-a", "-o", "out.txt"}, map[string]string{"all": "true", "output": "out.txt"}, nil},
+		{"value like a parameter", []string{"--output", "--all"}, map[string]string{"output": "--all", "all": "false"}, nil},
+		{"terminator", []string{"--", "--all"}, map[string]string{"all": "false", "source": "--all"}, nil},
+		{"unknown", []string{"--nope"}, nil, []error{ErrUnknownParameter}},
+	})
+}
+
+func TestParsePosixGroups(t *testing.T) {
+	runParseTests(t, CommandSet{Name: "app", IsPosix: true, AllowPosixGroups: true}, []parseTest{
+		{"group", []string{"-av", "in.txt"}, map[string]string{"all": "true", "verbose": "1", "source": "in.txt"}, nil},
+		{"attached value", []string{"-oout.txt"}, map[string]string{"output": "out.txt"}, nil},
+		{"group value", []string{"-vao", "out.txt"}, map[string]string{"all": "true", "output": "out.txt"}, nil},
+		{"group attached value", []string{"-aoout.txt"}, map[string]string{"all": "true", "output": "out.txt"}, nil},
+		{"negative number", []string{"-12"}, map[string]string{"source": "-12"}, nil},
+		{"negative decimal", []string{"-1.5"}, map[string]string{"source": "-1.5"}, nil},
+		{"unknown letter", []string{"-ax"}, nil, []error{ErrUnknownParameter}},
+	})
+	runParseTests(t, CommandSet{Name: "app", IsPosix: true}, []parseTest{
+		{"groups off", []string{"-av"}, nil, []error{ErrUnknownParameter}},
+	})
+}
+
+func TestParseRuneImp(t *testing.T) {
+	runParseTests(t, CommandSet{Name: "app", IsRuneImp: true, IsGNU: true, Prefix: []string{"-", "--"}}, []parseTest{
+		{"group", []string{"--av"}, map[string]string{"all": "true", "verbose": "1"}, nil},
+		{"group value", []string{"--ao", "out.txt"}, map[string]string{"all": "true", "output": "out.txt"}, nil},
+		{"long", []string{"--output", "out.txt"}, map[string]string{"output": "out.txt"}, nil},
+		{"single hyphen group", []string{"-av"}, nil, []error{ErrUnknownParameter}},
+		{"unknown letter", []string{"--ax"}, nil, []error{ErrUnknownParameter}},
+	})
+}
+
+func TestRuneImpPosixGroups(t *testing.T) {
+	if _, err := New(CommandSet{Name: "app", IsRuneImp: true, AllowPosixGroups: true}); !errors.Is(err, ErrRuneImpPosixGroups) {
+		t.Errorf("New() = %v, want %v", err, ErrRuneImpPosixGroups)
+	}
+
+	c, err := New(CommandSet{Name: "app", IsRuneImp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPosixGroups(true); !errors.Is(err, ErrRuneImpPosixGroups) {
+		t.Errorf("SetPosixGroups() = %v, want %v", err, ErrRuneImpPosixGroups)
+	}
+	if _, err := c.AddCommand(CommandSet{Name: "sub", AllowPosixGroups: true}); !errors.Is(err, ErrRuneImpPosixGroups) {
+		t.Errorf("AddCommand() = %v, want %v", err, ErrRuneImpPosixGroups)
+	}
+
+	c, err = New(CommandSet{Name: "app", AllowPosixGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetRuneImp(true); !errors.Is(err, ErrRuneImpPosixGroups) {
+		t.Errorf("SetRuneImp() = %v, want %v", err, ErrRuneImpPosixGroups)
+	}
+}
+
+func TestParseMultics(t *testing.T) {
+	runParseTests(t, CommandSet{Name: "app", IsMultics: true}, []parseTest{
+		{"words", []string{"-verbose", "-output", "out.txt", "in.txt"}, map[string]string{"verbose": "1", "output": "out.txt", "source": "in.txt"}, nil},
+		{"letters", []string{"-a", "-o", "out.txt"}, map[string]string{"all": "true", "output": "out.txt"}, nil},
+		{"unknown", []string{"-nope"}, nil, []error{ErrUnknownParameter}},
+	})
+
+	c, err := New(CommandSet{Name: "app", IsMultics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dry, _ := c.AddFlag("dry-run", []string{"dry-run"}, nil, "Dry run")
+	for _, arg := range []string{"-dry-run", "-dry_run"} {
+		if err := c.Parse([]string{arg}); err != nil || dry.GetFlag() == false {
+			t.Errorf("Parse(%q) = %v, GetFlag() = %t, want true", arg, err, dry.GetFlag())
+		}
+	}
+}
+
+func TestMulticsNames(t *testing.T) {
+	tests := []struct {
+		name string
+		cs   CommandSet
+		add  []string
+		err  string
+	}{
+		{"ambiguous with groups", CommandSet{Name: "app", IsMultics: true, IsPosix: true, AllowPosixGroups: true}, []string{"x", "xray"}, ErrorMulticsAmbiguous},
+		{"no groups", CommandSet{Name: "app", IsMultics: true, IsPosix: true}, []string{"x", "xray"}, ""},
+		{"duplicate", CommandSet{Name: "app", IsMultics: true}, []string{"dry-run", "dry_run"}, ErrorMulticsDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.cs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.add {
+				_, err = c.AddFlag(name, []string{name}, nil, "")
+				if err != nil {
+					break
+				}
+			}
+			if len(tt.err) == 0 && err != nil || len(tt.err) > 0 && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("AddFlag() = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+}
+
+func TestParseCommands(t *testing.T) {
+	c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+	c.SetPersistent("verbose", true)
+	remote, err := c.AddCommand(CommandSet{Name: "remote", AllowPosixGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	add, err := remote.AddCommand(CommandSet{Name: "add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	force, _ := add.AddFlag("force", []string{"f", "force"}, nil, "Force")
+	name, _ := add.AddArgument("name", []string{"name"}, "The remote name")
+	if add.IsGNU == false || add.AllowPosixGroups == false {
+		t.Errorf("add IsGNU, AllowPosixGroups = %t, %t, want both inherited", add.IsGNU, add.AllowPosixGroups)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		command string
+		errs    []error
+	}{
+		{"root", []string{"--all"}, "app", nil},
+		{"nested", []string{"remote", "add", "origin"}, "add", nil},
+		{"persistent", []string{"remote", "add", "-vf", "origin"}, "add", nil},
+		{"root parameter after command", []string{"remote", "--all"}, "remote", []error{ErrUnknownParameter}},
+		{"argument before command", []string{"in.txt", "remote"}, "remote", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			if c.Command.Name != tt.command {
+				t.Errorf("Command = %q, want %q", c.Command.Name, tt.command)
+			}
+		})
+	}
+
+	if err := c.Parse([]string{"remote", "add", "-vf", "origin"}); err != nil {
+		t.Fatal(err)
+	}
+	verbose, _ := c.Parameters["verbose"].GetInt()
+	if v, _ := name.GetValue(); v != "origin" || force.GetFlag() == false || verbose != 1 {
+		t.Errorf("name, force, verbose = %q, %t, %d, want origin, true, 1", v, force.GetFlag(), verbose)
+	}
+
+	// An error other than a ParseError from a subcommand is returned unchanged
+	add.IsRuneImp = true
+	if err := c.Parse([]string{"remote", "add"}); !errors.Is(err, ErrRuneImpPosixGroups) {
+		t.Errorf("Parse() = %v, want %v", err, ErrRuneImpPosixGroups)
+	}
+}
+
+// TestParseRepeated parses several argument slices with one instance. Each parse starts from the defaults.
+func TestParseRepeated(t *testing.T) {
+	c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+	c.Parameters["output"].SetDefault("out.txt")
+	sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"app", "--all"}
+
+	tests := []parseTest{
+		{"values", []string{"-v", "--output", "a.txt", "in.txt"}, map[string]string{"verbose": "1", "all": "false", "output": "a.txt", "source": "in.txt"}, nil},
+		{"cleared", nil, map[string]string{"verbose": "0", "all": "false", "output": "out.txt", "source": ""}, nil},
+		{"error", []string{"--nope"}, nil, []error{ErrUnknownParameter}},
+		{"after error", []string{"--all"}, map[string]string{"all": "true"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			checkValues(t, c.CommandSet, tt.want)
+			if c.Command != c.CommandSet {
+				t.Errorf("Command = %q, want %q", c.Command.Name, c.Name)
+			}
+		})
+	}
+
+	if err := c.Parse([]string{"sub"}); err != nil || c.Command != sub {
+		t.Errorf("Parse(sub) = %v, Command = %q, want sub", err, c.Command.Name)
+	}
+	if err := c.Parse(nil); err != nil || c.Command != c.CommandSet {
+		t.Errorf("Parse(nil) = %v, Command = %q, want app", err, c.Command.Name)
+	}
+}
+
+func TestAddParameterNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		add    func(c *Cliopatra) error
+		exists bool
+	}{
+		{"same name", func(c *Cliopatra) error {
+			_, err := c.AddFlag("b", []string{"x"}, nil, "")
+			return err
+		}, true},
+		{"option and flag", func(c *Cliopatra) error {
+			_, err := c.AddOption("b", []string{"y", "x"}, nil, "")
+			return err
+		}, true},
+		{"other prefix", func(c *Cliopatra) error {
+			_, err := c.AddFlag("b", []string{"x"}, &[]string{"+"}, "")
+			return err
+		}, false},
+		{"gnu long name", func(c *Cliopatra) error {
+			_, err := c.AddFlag("b", []string{"long"}, nil, "")
+			return err
+		}, true},
+		{"argument", func(c *Cliopatra) error {
+			_, err := c.AddArgument("b", []string{"x"}, "")
+			return err
+		}, false},
+		{"inherited", func(c *Cliopatra) error {
+			sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+			_, err := sub.AddFlag("b", []string{"x"}, nil, "")
+			return err
+		}, true},
+		{"inherited redefined", func(c *Cliopatra) error {
+			sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+			_, err := sub.AddFlag("a", []string{"x"}, nil, "")
+			return err
+		}, false},
+		{"parent not persistent", func(c *Cliopatra) error {
+			c.SetPersistent("a", false)
+			sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+			_, err := sub.AddFlag("b", []string{"x"}, nil, "")
+			return err
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.AddFlag("a", []string{"x"}, nil, "")
+			c.AddFlag("long", []string{"long"}, &[]string{"--"}, "")
+			c.SetPersistent("a", true)
+			err = tt.add(c)
+			if got := err != nil && strings.Contains(err.Error(), ErrorNameExists); got != tt.exists || err != nil && !got {
+				t.Errorf("error = %v, want name exists %t", err, tt.exists)
+			}
+		})
+	}
+
+	// A redefined inherited parameter is matched in place of the parent's
+	c, _ := New(CommandSet{Name: "app"})
+	a, _ := c.AddFlag("a", []string{"x"}, nil, "")
+	c.SetPersistent("a", true)
+	sub, _ := c.AddCommand(CommandSet{Name: "sub"})
+	subA, _ := sub.AddFlag("a", []string{"x"}, nil, "")
+	for i := 0; i < 20; i++ {
+		if err := c.Parse([]string{"sub", "-x"}); err != nil || a.GetFlag() || !subA.GetFlag() {
+			t.Fatalf("Parse() = %v, parent %t, sub %t, want the sub flag set", err, a.GetFlag(), subA.GetFlag())
+		}
+	}
+}
+
+func TestParseRequired(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		keys []string
+		errs []error
+	}{
+		{"all missing", nil, nil, []string{"output", "source"}, []error{ErrMissingParameter}},
+		{"one missing", nil, []string{"in.txt"}, []string{"output"}, []error{ErrMissingParameter}},
+		{"given", nil, []string{"--output", "out.txt", "in.txt"}, nil, nil},
+		{"env default", map[string]string{"APP_OUTPUT": "env.txt"}, []string{"in.txt"}, nil, nil},
+		{"missing value", nil, []string{"in.txt", "--output"}, []string{"output"}, []error{ErrMissingValue}},
+		{"empty inline value", nil, []string{"in.txt", "--output="}, []string{"output"}, []error{ErrMissingValue}},
+		{"errors collected", nil, []string{"--nope", "in.txt", "extra.txt", "--output"}, []string{"", "output", ""}, []error{ErrUnknownParameter, ErrUnexpectedArgument, ErrMissingValue}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}, EnvPrefix: "APP_"})
+			c.Parameters["output"].SetRequired(true)
+			c.Parameters["output"].SetEnv([]string{"OUTPUT"})
+			c.Parameters["source"].SetRequired(true)
+
+			err := c.Parse(tt.args)
+			checkErrors(t, err, tt.errs)
+			var errs ParseErrors
+			var pe *ParseError
+			switch {
+			case errors.As(err, &errs):
+			case errors.As(err, &pe):
+				errs = ParseErrors{pe}
+			}
+			keys := []string{}
+			for _, e := range errs {
+				keys = append(keys, e.Key)
+			}
+			if fmt.Sprint(keys) != fmt.Sprint(tt.keys) && len(keys)+len(tt.keys) > 0 {
+				t.Errorf("error keys = %q, want %q", keys, tt.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	CompleteCommand   = "__complete" // The hidden command the completion scripts call to get completion candidates
+	ErrorShellUnknown = "unknown shell for completion. Supported shells are bash, fish and zsh"
+)
+
+const bashCompletion = `# bash completion for %[1]s generated by CLIOPATra
+_%[2]s_cliopatra_complete() {
+	local line="${COMP_LINE:0:$COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	[[ "$line" == *" " ]] && words+=("")
+	local cur="${words[${#words[@]}-1]}"
+	local IFS=$'\n'
+	COMPREPLY=($("${words[0]}" %[3]s "${words[@]:1}" 2>/dev/null))
+	if [[ "$cur" == *=* && "$COMP_WORDBREAKS" == *=* ]]; then
+		COMPREPLY=("${COMPREPLY[@]#*=}")
+	fi
+}
+complete -o default -F _%[2]s_cliopatra_complete %[1]s
+`
+
+const fishCompletion = `# fish completion for %[1]s generated by CLIOPATra
+function __%[2]s_cliopatra_complete
+	set -l tokens (commandline -opc)
+	set -l cmd $tokens[1]
+	set -e tokens[1]
+	set -l current (commandline -ct)
+	$cmd %[3]s $tokens "$current" 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[2]s_cliopatra_complete)'
+`
+
+const zshCompletion = `#compdef %[1]s
+# zsh completion for %[1]s generated by CLIOPATra
+_%[2]s_cliopatra_complete() {
+	local -a candidates
+	candidates=(${(f)"$(${words[1]} %[3]s "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+compdef _%[2]s_cliopatra_complete %[1]s
+`
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	ErrShellUnknown = errors.New(ErrorShellUnknown) // Returned by WriteCompletion for unsupported shells
+	nonWordPattern  = regexp.MustCompile(`\W`)
+)
+
+/*
+ * TYPES
+ */
+
+// CompletionFunc lists the value candidates of an Option or Argument for shell completion. It is given the
+// partially typed value and the selected command set, with the parameters before the value already parsed.
+// Candidates not beginning with the partial value are dropped.
+type CompletionFunc func(partial string, cs *CommandSet) []string
+
+// Complete returns the completion candidates for the last of the words. The words are the command line
+// arguments after the command itself, with the last being the partially typed word which may be empty.
+// Concurrent calls on the same instance are serialized with Parse.
+func (c *Cliopatra) Complete(words []string) []string {
+	partial := ""
+	if len(words) > 0 {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Parse the preceding words for the completion functions, quietly as the candidates may share the output
+	l := c.logger
+	c.logger = logger{}
+	c.parse(words)
+	c.logger = l
+
+	cs := c.CommandSet
+	endOfOptions := false
+	positionals := 0
+	var valueFor CommandLineParameter
+	for _, word := range words {
+		if valueFor != nil {
+			valueFor = nil
+			continue
+		}
+		if endOfOptions == false {
+			if (cs.IsGNU || cs.IsRuneImp) && word == OptionTerminator {
+				endOfOptions = true
+				continue
+			}
+			if sub, ok := cs.Commands[word]; ok {
+				cs = sub
+				positionals = 0
+				continue
+			}
+			if _, pv, _, inline := cs.matchParameter(word); pv != nil {
+				if o, ok := pv.(*Option); ok && o.valueRequired && inline == false {
+					valueFor = pv
+				}
+				continue
+			}
+			if cs.looksLikeParameter(word) {
+				continue
+			}
+		}
+		positionals++
+	}
+
+	if valueFor != nil {
+		return cs.completeValue(valueFor, "", partial)
+	}
+	if endOfOptions == false && cs.IsGNU && strings.Contains(partial, GNUValueSeparator) {
+		sep := strings.Index(partial, GNUValueSeparator) + len(GNUValueSeparator)
+		if _, pv, _, inline := cs.matchParameter(partial); pv != nil && inline {
+			return cs.completeValue(pv, partial[:sep], partial[sep:])
+		}
+	}
+
+	candidates := []string{}
+	if endOfOptions == false {
+		for _, name := range cs.commandNames() {
+			if strings.HasPrefix(name, partial) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	if pk := cs.argumentAt(positionals); len(pk) > 0 {
+		candidates = append(candidates, cs.completeValue(cs.Parameters[pk], "", partial)...)
+	}
+	if endOfOptions == false && len(partial) > 0 {
+		for _, pk := range cs.helpKeys() {
+			pv := cs.lookupParameter(pk)
+			if _, ok := pv.(*Argument); ok {
+				continue
+			}
+			for _, label := range cs.parameterLabels(pv) {
+				if strings.HasPrefix(label, partial) {
+					candidates = append(candidates, label)
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// WriteCompletion writes the completion script for the shell. Supported shells are bash, fish and zsh.
+// The scripts call the program with the hidden __complete command to get the completion candidates.
+func (c *Cliopatra) WriteCompletion(w io.Writer, shell string) error {
+	name := c.Name
+	if len(name) == 0 {
+		name = filepath.Base(c.CliApp)
+	}
+	function := nonWordPattern.ReplaceAllString(name, "_")
+
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "fish":
+		script = fishCompletion
+	case "zsh":
+		script = zshCompletion
+	default:
+		return fmt.Errorf("%w: %q", ErrShellUnknown, shell)
+	}
+
+	_, err := fmt.Fprintf(w, script, name, function, CompleteCommand)
+	return err
+}
+
+// argumentAt returns the key of the argument taking the positional at index n, counting from zero, when it
+// is the last positional. Mirrors the assignment done by assignArguments. Empty if no argument takes it.
+func (cs CommandSet) argumentAt(n int) string {
+	count := n + 1
+	owner := make([]string, count)
+	take := func(pk string, from int, max int) {
+		for j := from; j < count && max > 0; j++ {
+			if len(owner[j]) == 0 {
+				owner[j] = pk
+				max--
+			}
+		}
+	}
+
+	trailing := []string{}
+	for _, pk := range cs.parameterKeys() {
+		a, ok := cs.Parameters[pk].(*Argument)
+		if !ok {
+			continue
+		}
+		if a.Position == 0 {
+			trailing = append(trailing, pk)
+			continue
+		}
+		_, max := a.arityRange(count)
+		take(pk, int(a.Position)-1, max)
+	}
+
+	for i, pk := range trailing {
+		a := cs.Parameters[pk].(*Argument)
+		remaining := 0
+		for _, o := range owner {
+			if len(o) == 0 {
+				remaining++
+			}
+		}
+		_, max := a.arityRange(remaining)
+		if a.arity == ArityVariadic {
+			for _, later := range trailing[i+1:] {
+				laterMin, _ := cs.Parameters[later].(*Argument).arityRange(0)
+				max -= laterMin
+			}
+		}
+		take(pk, 0, max)
+	}
+	return owner[n]
+}
+
+// completeValue returns the completion candidates for the value of the parameter. The prefix is
+// prepended to each candidate. i.e.: --output=
+func (cs *CommandSet) completeValue(pv CommandLineParameter, prefix string, partial string) []string {
+	var f CompletionFunc
+	switch p := pv.(type) {
+	case *Argument:
+		f = p.completion
+	case *Option:
+		f = p.completion
+	}
+	if f == nil {
+		return nil
+	}
+
+	candidates := []string{}
+	for _, v := range f(partial, cs) {
+		if strings.HasPrefix(v, partial) {
+			candidates = append(candidates, prefix+v)
+		}
+	}
+	return candidates
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// CompleteFiles returns a CompletionFunc listing the files matching the glob pattern, and directories to
+// descend into. Hidden files are only listed once a dot is typed. i.e.: *.json
+func CompleteFiles(pattern string) CompletionFunc {
+	return func(partial string, cs *CommandSet) []string {
+		typed := partial[:strings.LastIndex(partial, string(filepath.Separator))+1]
+		dir := typed
+		if len(dir) == 0 {
+			dir = "."
+		}
+		candidates := []string{}
+		entries, _ := ioutil.ReadDir(dir)
+		hidden := strings.HasPrefix(partial[len(typed):], ".")
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") && !hidden {
+				continue
+			}
+			if e.IsDir() {
+				candidates = append(candidates, typed+e.Name()+string(filepath.Separator))
+			} else if ok, _ := filepath.Match(pattern, e.Name()); ok {
+				candidates = append(candidates, typed+e.Name())
+			}
+		}
+		return candidates
+	}
+}
+
+// CompleteValues returns a CompletionFunc listing a fixed set of values. i.e.: an enum
+func CompleteValues(values ...string) CompletionFunc {
+	return func(partial string, cs *CommandSet) []string {
+		return values
+	}
+}
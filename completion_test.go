@@ -0,0 +1,213 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newCompletionApp returns an app with a flag, an option, an argument and a subcommand for completion
+func newCompletionApp(t *testing.T) *Cliopatra {
+	t.Helper()
+	c, err := New(CommandSet{Name: "app", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddFlag("force", []string{"f", "force"}, nil, "Overwrite existing files")
+	c.AddOption("output", []string{"o", "output"}, nil, "The output file")
+	c.AddArgument("source", []string{"SOURCE"}, "The source")
+	c.AddCommand(CommandSet{Name: "remote", Summery: "Manage remotes"})
+	c.AddCommand(CommandSet{Name: "restore", Summery: "Restore files"})
+	return c
+}
+
+func TestWriteCompletion(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+		err   error
+	}{
+		{"bash", []string{"_my_app_cliopatra_complete() {", `"${words[0]}" __complete`, "complete -o default -F _my_app_cliopatra_complete my-app\n"}, nil},
+		{"fish", []string{"function __my_app_cliopatra_complete", "$cmd __complete $tokens", "complete -c my-app -f -a '(__my_app_cliopatra_complete)'\n"}, nil},
+		{"zsh", []string{"#compdef my-app\n", "${words[1]} __complete", "compdef _my_app_cliopatra_complete my-app\n"}, nil},
+		{"powershell", nil, ErrShellUnknown},
+	}
+
+	c, err := New(CommandSet{Name: "my-app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			b := &strings.Builder{}
+			err := c.WriteCompletion(b, tt.shell)
+			if errors.Is(err, tt.err) == false || (tt.err == nil && err != nil) {
+				t.Fatalf("WriteCompletion() error = %v, want %v", err, tt.err)
+			}
+			for _, want := range tt.want {
+				if strings.Contains(b.String(), want) == false {
+					t.Errorf("WriteCompletion() =\n%s\nwant it to contain %q", b.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"nothing typed", nil, []string{"remote", "restore"}},
+		{"empty word", []string{""}, []string{"remote", "restore"}},
+		{"command prefix", []string{"rem"}, []string{"remote"}},
+		{"hyphen", []string{"-"}, []string{"-h", "--help", "-f", "--force", "-o", "--output"}},
+		{"long prefix", []string{"--f"}, []string{"--force"}},
+		{"subcommand parameters", []string{"remote", "-"}, []string{"-h", "--help"}},
+		{"option value", []string{"-o", ""}, nil},
+		{"after terminator", []string{"--", "r"}, nil},
+		{"no match", []string{"x"}, nil},
+	}
+
+	c := newCompletionApp(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Complete(tt.words); fmt.Sprint(got) != fmt.Sprint(tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("Complete(%q) = %q, want %q", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentAt(t *testing.T) {
+	type argument struct {
+		key      string
+		arity    int
+		position uint
+		required bool
+	}
+	tests := []struct {
+		name      string
+		arguments []argument
+	}{
+		{"single", []argument{{"file", 1, 0, false}}},
+		{"pair", []argument{{"pair", 2, 0, false}}},
+		{"fixed positions", []argument{{"dest", 1, 2, false}, {"src", 1, 1, false}}},
+		{"optional", []argument{{"first", ArityOptional, 0, false}, {"second", 1, 0, false}}},
+		{"variadic", []argument{{"files", ArityVariadic, 0, false}}},
+		{"variadic reserve", []argument{{"rest", ArityVariadic, 0, false}, {"last", 1, 0, false}, {"pair", 2, 0, false}}},
+		{"variadic required", []argument{{"rest", ArityVariadic, 0, true}, {"last", 1, 0, false}}},
+		{"mixed", []argument{{"rest", ArityVariadic, 0, false}, {"dest", 1, 2, false}, {"last", 1, 0, false}, {"src", 1, 1, true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, spec := range tt.arguments {
+				a, _ := c.AddArgument(spec.key, []string{spec.key}, "")
+				a.SetArity(spec.arity)
+				a.SetPosition(spec.position)
+				a.SetRequired(spec.required)
+			}
+
+			// The argument assignArguments gives the last positional to must be the one completed
+			args := []string{}
+			for n := 0; n < 6; n++ {
+				last := fmt.Sprintf("p%d", n)
+				args = append(args, last)
+				c.Parse(args)
+				want := ""
+				for _, spec := range tt.arguments {
+					if containsString(c.Parameters[spec.key].(*Argument).GetValues(), last) {
+						want = spec.key
+					}
+				}
+				if got := c.argumentAt(n); got != want {
+					t.Errorf("argumentAt(%d) = %q, assignArguments gave %q to %q", n, got, last, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionFunc(t *testing.T) {
+	c := newCompletionApp(t)
+	c.Parameters["output"].(*Option).SetCompletion(CompleteValues("json", "text", "toml"))
+	// The argument candidates depend on the command set and the parameters already parsed
+	c.Parameters["source"].(*Argument).SetCompletion(func(partial string, cs *CommandSet) []string {
+		if isSet(cs.Parameters["force"]) {
+			return []string{"forced-" + cs.Name}
+		}
+		return []string{"file-" + cs.Name, "other"}
+	})
+	r := c.GetCommand("remote")
+	a, _ := r.AddArgument("name", []string{"NAME"}, "The remote name")
+	a.SetCompletion(func(partial string, cs *CommandSet) []string {
+		return []string{"origin", "upstream", partial + "-copy"}
+	})
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"option value", []string{"-o", ""}, []string{"json", "text", "toml"}},
+		{"option value prefix", []string{"--output", "t"}, []string{"text", "toml"}},
+		{"option inline value", []string{"--output=j"}, []string{"--output=json"}},
+		{"argument", []string{""}, []string{"remote", "restore", "file-app", "other"}},
+		{"argument prefix", []string{"f"}, []string{"file-app"}},
+		{"argument after flag", []string{"--force", ""}, []string{"remote", "restore", "forced-app"}},
+		{"argument after terminator", []string{"--", "o"}, []string{"other"}},
+		{"subcommand argument", []string{"remote", "up"}, []string{"upstream", "up-copy"}},
+		{"argument taken", []string{"a", ""}, []string{"remote", "restore"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Complete(tt.words); fmt.Sprint(got) != fmt.Sprint(tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("Complete(%q) = %q, want %q", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.json", "b.txt", ".hidden.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir += string(filepath.Separator)
+	sub := dir + "sub" + string(filepath.Separator)
+
+	tests := []struct {
+		name    string
+		pattern string
+		partial string
+		want    []string
+	}{
+		{"pattern", "*.json", dir, []string{dir + "a.json", sub}},
+		{"all", "*", dir, []string{dir + "a.json", dir + "b.txt", sub}},
+		{"hidden", "*.json", dir + ".", []string{dir + ".hidden.json", dir + "a.json", sub}},
+		{"missing directory", "*", dir + "none" + string(filepath.Separator), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompleteFiles(tt.pattern)(tt.partial, nil); fmt.Sprint(got) != fmt.Sprint(tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("CompleteFiles(%q)(%q) = %q, want %q", tt.pattern, tt.partial, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package cliopatra
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+ * CONSTANTS
+ */
+
+// Config file formats
+const (
+	ConfigAuto ConfigFormat = iota // Detect the format from the file extension
+	ConfigINI                      // INI with [section] headers for subcommands
+	ConfigJSON                     // JSON with nested objects for subcommands
+	ConfigTOML                     // A subset of TOML with [table] headers for subcommands
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	errTOMLIncomplete = errors.New("unterminated array")
+	tomlDatePattern   = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+	tomlNumberPattern = regexp.MustCompile(`^([+-]?([0-9][0-9_]*(\.[0-9][0-9_]*)?([eE][+-]?[0-9][0-9_]*)?|inf|nan)|0x[0-9A-Fa-f_]+|0o[0-7_]+|0b[01_]+)$`)
+)
+
+/*
+ * TYPES
+ */
+
+// ConfigFormat is the file format of a config file
+type ConfigFormat int
+
+// configTable is the parsed content of a config file. Values are strings or nested tables.
+type configTable map[string]interface{}
+
+// SetConfigFile defines the config file used for parameter defaults. A missing file is ignored.
+// Config keys match parameter keys and subcommands are nested sections or tables named after the subcommand.
+// Persistent parameters may also be set in the section of the selected subcommand or one of its parents.
+func (c *Cliopatra) SetConfigFile(path string, format ConfigFormat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configFile = path
+	c.configFormat = format
+}
+
+// SetConfigOption defines the key of the option whose value, if given, replaces the config file path.
+// A missing file given by the option is an error.
+func (c *Cliopatra) SetConfigOption(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configOption = key
+}
+
+// loadConfig applies config file defaults to the command set tree. The selected command set is the one
+// chosen by the command line.
+func (c *Cliopatra) loadConfig(selected *CommandSet) error {
+	path := c.configFile
+	optional := true
+	if pv, ok := c.Parameters[c.configOption]; ok {
+		if v, err := pv.GetValue(); err == nil && len(v) > 0 {
+			path = v
+			optional = false
+		}
+	}
+	if len(path) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil
+		}
+		return &ParseError{Cause: err, Err: ErrConfig, Key: c.configOption, Value: path}
+	}
+	defer f.Close()
+
+	format := c.configFormat
+	if format == ConfigAuto {
+		format = configFormatOf(path)
+	}
+	table, err := parseConfig(f, format)
+	if err != nil {
+		return &ParseError{Cause: err, Err: ErrConfig, Key: c.configOption, Value: path}
+	}
+
+	c.CommandSet.applyConfig(table, selected)
+	return nil
+}
+
+// applyConfig stores config values for the parameters of the command set and its subcommands. Persistent
+// parameters inherited by a command set on the path to the selected command set may be set in its section,
+// the deepest section taking precedence.
+func (cs *CommandSet) applyConfig(table configTable, selected *CommandSet) {
+	params := cs.Parameters
+	if cs.isParentOf(selected) {
+		params = cs.allParameters()
+	}
+	for pk, pv := range params {
+		if v, ok := table[pk].(string); ok {
+			setConfigDefault(pv, v)
+		}
+	}
+	for name, sub := range cs.Commands {
+		if nested, ok := table[name].(configTable); ok {
+			sub.applyConfig(nested, selected)
+		}
+	}
+}
+
+// isParentOf returns true if the command set is the given command set or one of its parents
+func (cs *CommandSet) isParentOf(sub *CommandSet) bool {
+	for set := sub; set != nil; set = set.parent {
+		if set == cs {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// configFormatOf returns the config format for the file extension. JSON is used for unknown extensions.
+func configFormatOf(path string) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ini", ".cfg", ".conf":
+		return ConfigINI
+	case ".toml":
+		return ConfigTOML
+	}
+	return ConfigJSON
+}
+
+// parseConfig reads the config content in the given format
+func parseConfig(r io.Reader, format ConfigFormat) (configTable, error) {
+	switch format {
+	case ConfigINI, ConfigTOML:
+		return parseConfigSections(r, format)
+	}
+
+	raw := map[string]interface{}{}
+	decoder := json.NewDecoder(r)
+	// Numbers are kept as written so large integers are not rounded by float64
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return jsonTable(raw), nil
+}
+
+// jsonTable converts decoded JSON into a config table
+func jsonTable(raw map[string]interface{}) configTable {
+	table := configTable{}
+	for k, v := range raw {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			table[k] = jsonTable(t)
+		case []interface{}:
+			list := make([]string, len(t))
+			for i, item := range t {
+				list[i] = jsonScalar(item)
+			}
+			table[k] = strings.Join(list, ",")
+		default:
+			table[k] = jsonScalar(t)
+		}
+	}
+	return table
+}
+
+// jsonScalar returns the string form of a decoded JSON value
+func jsonScalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return fmt.Sprint(v)
+}
+
+// parseConfigSections reads INI or TOML content. Section and table names separated by periods nest
+// subcommands. i.e.: [remote.add]
+func parseConfigSections(r io.Reader, format ConfigFormat) (configTable, error) {
+	root := configTable{}
+	table := root
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") || format == ConfigINI && strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			end := strings.Index(text, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section %q", line, text)
+			}
+			table = root
+			for _, name := range strings.Split(text[1:end], ".") {
+				name = strings.TrimSpace(strings.Trim(strings.TrimSpace(name), `"`))
+				nested, ok := table[name].(configTable)
+				if !ok {
+					nested = configTable{}
+					table[name] = nested
+				}
+				table = nested
+			}
+			continue
+		}
+
+		sep := strings.Index(text, "=")
+		if sep < 0 && format == ConfigINI {
+			sep = strings.Index(text, ":")
+		}
+		if sep < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key := strings.Trim(strings.TrimSpace(text[:sep]), `"`)
+		raw := strings.TrimSpace(text[sep+1:])
+
+		var value string
+		var err error
+		if format == ConfigTOML {
+			value, err = tomlValue(raw)
+			// Arrays may continue over several lines
+			for err == errTOMLIncomplete && scanner.Scan() {
+				line++
+				raw += "\n" + scanner.Text()
+				value, err = tomlValue(raw)
+			}
+		} else {
+			value = iniValue(raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		table[key] = value
+	}
+
+	return root, scanner.Err()
+}
+
+// iniValue returns the INI value with surrounding quotes and any inline comment removed. An inline
+// comment begins with a semicolon or number sign after whitespace.
+func iniValue(raw string) string {
+	if len(raw) > 1 && (raw[0] == '"' || raw[0] == '\'') {
+		if end := strings.IndexByte(raw[1:], raw[0]); end >= 0 {
+			return raw[1 : end+1]
+		}
+	}
+	for i := 1; i < len(raw); i++ {
+		if (raw[i] == ';' || raw[i] == '#') && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+			return strings.TrimSpace(raw[:i])
+		}
+	}
+	return raw
+}
+
+// tomlValue returns the string form of a TOML string, number, boolean, date or array value. Array items
+// are joined with commas. Multi-line strings, nested arrays, inline tables and array items containing a
+// comma are not supported and return an error. errTOMLIncomplete is returned if an array continues past the end of the raw text.
+func tomlValue(raw string) (string, error) {
+	value, rest, err := tomlScan(raw)
+	if err != nil {
+		return "", err
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) > 0 && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after value", rest)
+	}
+	return value, nil
+}
+
+// tomlScan reads a TOML value from the beginning of the text and returns it with the remaining text
+func tomlScan(text string) (string, string, error) {
+	text = strings.TrimLeft(text, " \t")
+	switch {
+	case len(text) == 0:
+		return "", "", errors.New("missing value")
+	case strings.HasPrefix(text, `"""`), strings.HasPrefix(text, "'''"):
+		return "", "", errors.New("multi-line strings are not supported")
+	case text[0] == '"':
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '\n':
+				return "", "", fmt.Errorf("unterminated string %s", text[:i])
+			case '"':
+				value, err := strconv.Unquote(text[:i+1])
+				return value, text[i+1:], err
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string %s", text)
+	case text[0] == '\'':
+		end := strings.IndexAny(text[1:], "'\n")
+		if end < 0 || text[end+1] != '\'' {
+			return "", "", fmt.Errorf("unterminated string %s", text)
+		}
+		return text[1 : end+1], text[end+2:], nil
+	case text[0] == '[':
+		return tomlArray(text[1:])
+	case text[0] == '{':
+		return "", "", errors.New("inline tables are not supported")
+	}
+
+	end := strings.IndexAny(text, " \t\n,]#")
+	if end < 0 {
+		end = len(text)
+	}
+	bare := text[:end]
+	switch {
+	case bare == "true" || bare == "false":
+	case tomlNumberPattern.MatchString(bare):
+		bare = strings.ReplaceAll(bare, "_", "")
+	case tomlDatePattern.MatchString(bare):
+	default:
+		return "", "", fmt.Errorf("invalid value %q", bare)
+	}
+	return bare, text[end:], nil
+}
+
+// tomlArray reads the items of a TOML array after its opening bracket and returns them joined with commas
+func tomlArray(text string) (string, string, error) {
+	list := []string{}
+	for {
+		text = tomlSkip(text)
+		if len(text) == 0 {
+			return "", "", errTOMLIncomplete
+		}
+		if text[0] == ']' {
+			return strings.Join(list, ","), text[1:], nil
+		}
+		if text[0] == '[' {
+			return "", "", errors.New("nested arrays are not supported")
+		}
+
+		value, rest, err := tomlScan(text)
+		if err != nil {
+			return "", "", err
+		}
+		// Items are joined with commas so one containing a comma can not be told apart
+		if strings.Contains(value, ",") {
+			return "", "", fmt.Errorf("array items containing a comma are not supported: %q", value)
+		}
+		list = append(list, value)
+
+		text = tomlSkip(rest)
+		switch {
+		case len(text) == 0:
+			return "", "", errTOMLIncomplete
+		case text[0] == ',':
+			text = text[1:]
+		case text[0] != ']':
+			return "", "", fmt.Errorf("expected , or ] in array before %q", text)
+		}
+	}
+}
+
+// tomlSkip returns the text after any leading whitespace, newlines and comments
+func tomlSkip(text string) string {
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if strings.HasPrefix(text, "#") {
+			end := strings.IndexByte(text, '\n')
+			if end < 0 {
+				return ""
+			}
+			text = text[end:]
+			continue
+		}
+		return text
+	}
+}
+
+// setConfigDefault stores the config value for the parameter and resolves its current value
+func setConfigDefault(pv CommandLineParameter, v string) {
+	p := parameterOf(pv)
+	p.configDefault, p.defaultSet = v, true
+	resolveDefault(pv)
+}
@@ -0,0 +1,150 @@
+package cliopatra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.json":  `{"output": "json.txt", "id": 12345678901234567891, "ratio": 0.5, "remote": {"url": "json", "verbose": 2}}`,
+		"app.ini":   "output = ini.txt ; comment\n[remote]\nurl = \"ini\"\nverbose: 3\n",
+		"app.toml":  "output = \"toml.txt\" # comment\ntags = [\n  \"a\", # first\n  \"b\",\n]\n[remote]\nurl = 'toml'\n",
+		"bad.json":  `{"output": `,
+		"bad.toml":  "tags = [\"a\"\n",
+		"other.cfg": "output = other.txt\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		preferred bool
+		args      []string
+		want      map[string]string
+		url       string
+		errs      []error
+	}{
+		{"default", "", nil, false, nil, map[string]string{"output": "default.txt"}, "", nil},
+		{"missing file", "none.json", nil, false, nil, map[string]string{"output": "default.txt"}, "", nil},
+		{"json", "app.json", nil, false, nil, map[string]string{"output": "json.txt", "id": "12345678901234567891", "ratio": "0.5", "verbose": "0"}, "json", nil},
+		{"ini", "app.ini", nil, false, nil, map[string]string{"output": "ini.txt"}, "ini", nil},
+		{"cfg", "other.cfg", nil, false, nil, map[string]string{"output": "other.txt"}, "", nil},
+		{"toml", "app.toml", nil, false, nil, map[string]string{"output": "toml.txt", "tags": "a,b"}, "toml", nil},
+		{"inherited in selected section", "app.json", nil, false, []string{"remote"}, map[string]string{"verbose": "2"}, "json", nil},
+		{"inherited in ini section", "app.ini", nil, false, []string{"remote"}, map[string]string{"verbose": "3"}, "ini", nil},
+		{"env over config", "app.json", map[string]string{"APP_OUTPUT": "env.txt"}, false, nil, map[string]string{"output": "env.txt"}, "json", nil},
+		{"config preferred", "app.json", map[string]string{"APP_OUTPUT": "env.txt"}, true, nil, map[string]string{"output": "json.txt"}, "json", nil},
+		{"command line over env", "app.json", map[string]string{"APP_OUTPUT": "env.txt"}, false, []string{"--output", "cli.txt"}, map[string]string{"output": "cli.txt"}, "json", nil},
+		{"config option", "app.json", nil, false, []string{"--config", filepath.Join(dir, "app.ini")}, map[string]string{"output": "ini.txt"}, "ini", nil},
+		{"config option missing", "app.json", nil, false, []string{"--config", filepath.Join(dir, "none.json")}, nil, "", []error{ErrConfig}},
+		{"invalid json", "bad.json", nil, false, nil, nil, "", []error{ErrConfig}},
+		{"invalid toml", "bad.toml", nil, false, nil, nil, "", []error{ErrConfig}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}, EnvPrefix: "APP_"})
+			o := c.Parameters["output"].(*Option)
+			o.SetDefault("default.txt")
+			o.SetEnv([]string{"OUTPUT"})
+			o.SetConfigPreferred(tt.preferred)
+			c.SetPersistent("verbose", true)
+			for _, key := range []string{"config", "id", "ratio", "tags"} {
+				c.AddOption(key, []string{key}, nil, "")
+			}
+			c.SetConfigOption("config")
+			remote, _ := c.AddCommand(CommandSet{Name: "remote"})
+			url, _ := remote.AddOption("url", []string{"url"}, nil, "The remote URL")
+			if len(tt.file) > 0 {
+				c.SetConfigFile(filepath.Join(dir, tt.file), ConfigAuto)
+			}
+
+			checkErrors(t, c.Parse(tt.args), tt.errs)
+			if len(tt.errs) > 0 {
+				return
+			}
+			checkValues(t, c.CommandSet, tt.want)
+			if got, _ := url.GetValue(); got != tt.url {
+				t.Errorf("url GetValue() = %q, want %q", got, tt.url)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  ConfigFormat
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{"ini quoted", ConfigINI, `a = "x ; y"` + "\nb = 'z'", map[string]string{"a": "x ; y", "b": "z"}, ""},
+		{"ini comments", ConfigINI, "; comment\n# comment\na = x # note\nb = y;z", map[string]string{"a": "x", "b": "y;z"}, ""},
+		{"ini colon", ConfigINI, "a: x", map[string]string{"a": "x"}, ""},
+		{"ini section", ConfigINI, "[remote.add]\na = x", map[string]string{"remote.add.a": "x"}, ""},
+		{"toml strings", ConfigTOML, `a = "x # y"` + "\nb = 'c:\\dir'\nc = \"tab\\there\"", map[string]string{"a": "x # y", "b": `c:\dir`, "c": "tab\there"}, ""},
+		{"toml bare", ConfigTOML, "a = true\nb = 1_000\nc = 1979-05-27T07:32:00Z\nd = -1.5e3", map[string]string{"a": "true", "b": "1000", "c": "1979-05-27T07:32:00Z", "d": "-1.5e3"}, ""},
+		{"toml array", ConfigTOML, "a = [ 1, 2 ]\nb = []", map[string]string{"a": "1,2", "b": ""}, ""},
+		{"toml table", ConfigTOML, "[remote]\na = 'x'", map[string]string{"remote.a": "x"}, ""},
+		{"toml invalid bare", ConfigTOML, "a = yes", nil, "invalid value"},
+		{"toml trailing text", ConfigTOML, `a = "x" y`, nil, "after value"},
+		{"toml multi-line string", ConfigTOML, `a = """x"""`, nil, "multi-line"},
+		{"toml inline table", ConfigTOML, "a = { b = 1 }", nil, "inline tables"},
+		{"toml nested array", ConfigTOML, "a = [[1]]", nil, "nested arrays"},
+		{"toml comma item", ConfigTOML, `a = ["x,y"]`, nil, "comma"},
+		{"toml unterminated string", ConfigTOML, `a = "x`, nil, "unterminated string"},
+		{"missing separator", ConfigTOML, "a", nil, "expected key = value"},
+		{"unterminated section", ConfigINI, "[remote", nil, "unterminated section"},
+		{"json", ConfigJSON, `{"a": [1, "x", true], "b": null, "c": {"d": 12345678901234567891}}`, map[string]string{"a": "1,x,true", "b": "", "c.d": "12345678901234567891"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := parseConfig(strings.NewReader(tt.content), tt.format)
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("parseConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			flattenConfig(table, "", got)
+			if len(got) != len(tt.want) {
+				t.Errorf("parseConfig() = %q, want %q", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+// flattenConfig stores the values of the config table with period separated keys
+func flattenConfig(table configTable, prefix string, flat map[string]string) {
+	for k, v := range table {
+		switch t := v.(type) {
+		case configTable:
+			flattenConfig(t, prefix+k+".", flat)
+		case string:
+			flat[prefix+k] = t
+		}
+	}
+}
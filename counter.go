@@ -0,0 +1,50 @@
+package cliopatra
+
+import (
+	"strconv"
+)
+
+/*
+ * TYPES
+ */
+
+// SetCounter defines the flag as a counter. GetInt returns the number of times it was given on the
+// command line, including within groups. i.e.: -vvv or -v -v -v for 3. Without the flag on the command
+// line the count is the environment, config or default value, which may be a number or a truthy string.
+func (f *Flag) SetCounter(b bool) {
+	f.counter = b
+}
+
+// SetDecrement defines the flag as decrementing the count of the counter flag each time it is given.
+// i.e.: -q for -v
+func (f *Flag) SetDecrement(counter *Flag) {
+	f.decrements = counter
+	if counter != nil {
+		counter.counter = true
+	}
+}
+
+// counterBase returns the count of a counter flag from the environment, config or default value
+func (f *Flag) counterBase() int {
+	if f.defaultSet {
+		if v := f.externalDefault(); len(v) > 0 {
+			return countString(v)
+		}
+	}
+	return f.defaultCount
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// countString converts a count given as a number or a truthy string
+func countString(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if truthyString(s) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package cliopatra
+
+import (
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	gnu := CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}}
+	posix := CommandSet{Name: "app", IsPosix: true, AllowPosixGroups: true}
+
+	tests := []struct {
+		name       string
+		cs         CommandSet
+		defaultSet string
+		env        string
+		args       []string
+		want       map[string]string
+	}{
+		{"none", gnu, "", "", nil, map[string]string{"verbose": "0"}},
+		{"counter repeated", gnu, "", "", []string{"-v", "-v", "--verbose"}, map[string]string{"verbose": "3"}},
+		{"counter inline", gnu, "", "", []string{"--verbose=3"}, map[string]string{"verbose": "3"}},
+		{"counter group", posix, "", "", []string{"-vvv"}, map[string]string{"verbose": "3"}},
+		{"decrement", gnu, "", "", []string{"-v", "-v", "-q"}, map[string]string{"verbose": "1"}},
+		{"decrement inline", gnu, "", "", []string{"--verbose=3", "--quiet=2"}, map[string]string{"verbose": "1"}},
+		{"decrement group", posix, "", "", []string{"-vvqv"}, map[string]string{"verbose": "2"}},
+		{"decrement below zero", gnu, "", "", []string{"-q"}, map[string]string{"verbose": "-1"}},
+		{"default", gnu, "2", "", nil, map[string]string{"verbose": "2"}},
+		{"default replaced", gnu, "2", "", []string{"-v"}, map[string]string{"verbose": "1"}},
+		{"env number", gnu, "", "2", nil, map[string]string{"verbose": "2"}},
+		{"env truthy", gnu, "", "yes", []string{"-q"}, map[string]string{"verbose": "0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestApp(t, tt.cs)
+			verbose := c.Parameters["verbose"].(*Flag)
+			quiet, err := c.AddFlag("quiet", []string{"q", "quiet"}, nil, "Less output")
+			if err != nil {
+				t.Fatal(err)
+			}
+			quiet.SetDecrement(verbose)
+			if len(tt.defaultSet) > 0 {
+				verbose.SetDefault(tt.defaultSet)
+			}
+			if len(tt.env) > 0 {
+				verbose.SetEnv([]string{"TEST_COUNTER_VERBOSE"})
+				t.Setenv("TEST_COUNTER_VERBOSE", tt.env)
+			}
+
+			if err := c.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			checkValues(t, c.CommandSet, tt.want)
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package cliopatra
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	DefaultManSection = "1" // The man page section for user commands
+)
+
+/*
+ * TYPES
+ */
+
+// GenerateMan writes a roff man(7) page for the command set and each of its subcommands into the directory.
+// Pages are named after the command path. i.e.: app-remote-add.1
+func (cs *CommandSet) GenerateMan(dir string, section string) error {
+	if len(section) == 0 {
+		section = DefaultManSection
+	}
+	return cs.generateDocs(dir, "."+section, func(set *CommandSet, w io.Writer) error {
+		return set.WriteMan(w, section)
+	})
+}
+
+// GenerateMarkdown writes a Markdown reference page for the command set and each of its subcommands into
+// the directory. Pages are named after the command path and link to their subcommands. i.e.: app-remote-add.md
+func (cs *CommandSet) GenerateMarkdown(dir string) error {
+	return cs.generateDocs(dir, ".md", func(set *CommandSet, w io.Writer) error {
+		return set.WriteMarkdown(w)
+	})
+}
+
+// WriteMan writes the roff man(7) page for the command set
+func (cs CommandSet) WriteMan(w io.Writer, section string) error {
+	if len(section) == 0 {
+		section = DefaultManSection
+	}
+	data := cs.HelpData()
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, ".TH %q %q\n", strings.ToUpper(docName(data.Path)), section)
+	b.WriteString(".SH NAME\n")
+	b.WriteString(roffEscape(docName(data.Path)))
+	if len(data.Summery) > 0 {
+		b.WriteString(` \- ` + roffEscape(data.Summery))
+	}
+	b.WriteString("\n.SH SYNOPSIS\n")
+	b.WriteString(".B " + roffEscape(data.Path) + "\n")
+	if args := strings.TrimSpace(strings.TrimPrefix(data.Usage, data.Path)); len(args) > 0 {
+		b.WriteString(roffEscape(args) + "\n")
+	}
+	if len(data.Description) > 0 {
+		b.WriteString(".SH DESCRIPTION\n")
+		b.WriteString(roffParagraphs(data.Description))
+	}
+
+	options := append(append([]HelpParameter{}, data.Flags...), data.Options...)
+	if len(options) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, hp := range options {
+			b.WriteString(".TP\n.B " + roffEscape(strings.ReplaceAll(hp.Label, " | ", ", ")) + "\n")
+			b.WriteString(roffEscape(hp.Text) + "\n")
+		}
+	}
+	if len(data.Arguments) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, hp := range data.Arguments {
+			b.WriteString(".TP\n.I " + roffEscape(hp.Label) + "\n")
+			b.WriteString(roffEscape(hp.Text) + "\n")
+		}
+	}
+	if len(data.Commands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, hc := range data.Commands {
+			b.WriteString(".TP\n.B " + roffEscape(hc.Name) + "\n")
+			b.WriteString(roffEscape(hc.Summery) + "\n")
+		}
+	}
+
+	env := []string{}
+	for _, hp := range data.Parameters {
+		for _, name := range hp.Env {
+			env = append(env, ".TP\n.B "+roffEscape(name)+"\n"+roffEscape(hp.Help)+"\n")
+		}
+	}
+	if len(env) > 0 {
+		b.WriteString(".SH ENVIRONMENT\n" + strings.Join(env, ""))
+	}
+	if len(data.Help) > 0 {
+		b.WriteString(".SH NOTES\n")
+		b.WriteString(roffParagraphs(data.Help))
+	}
+
+	related := []string{}
+	if cs.parent != nil {
+		related = append(related, docName(cs.parent.commandPath()))
+	}
+	for _, hc := range data.Commands {
+		related = append(related, docName(data.Path+" "+hc.Name))
+	}
+	if len(related) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i, name := range related {
+			sep := ","
+			if i == len(related)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(b, ".BR %s (%s)%s\n", roffEscape(name), section, sep)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMarkdown writes the Markdown reference page for the command set
+func (cs CommandSet) WriteMarkdown(w io.Writer) error {
+	data := cs.HelpData()
+	b := &strings.Builder{}
+
+	b.WriteString("# " + data.Path + "\n\n")
+	if len(data.Summery) > 0 {
+		b.WriteString(data.Summery + "\n\n")
+	}
+	b.WriteString("## Usage\n\n```\n" + data.Usage + "\n```\n\n")
+	if len(data.Description) > 0 {
+		b.WriteString(data.Description + "\n\n")
+	}
+
+	sections := []struct {
+		title  string
+		params []HelpParameter
+	}{
+		{"Flags", data.Flags},
+		{"Options", data.Options},
+		{"Arguments", data.Arguments},
+	}
+	for _, section := range sections {
+		if len(section.params) == 0 {
+			continue
+		}
+		b.WriteString("## " + section.title + "\n\n")
+		for _, hp := range section.params {
+			labels := strings.Split(hp.Label, " | ")
+			b.WriteString("- `" + strings.Join(labels, "`, `") + "`")
+			if len(hp.Text) > 0 {
+				b.WriteString(": " + markdownEscape(hp.Text))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(data.Commands) > 0 {
+		b.WriteString("## Commands\n\n")
+		for _, hc := range data.Commands {
+			fmt.Fprintf(b, "- [%s](%s.md)", hc.Name, docName(data.Path+" "+hc.Name))
+			if len(hc.Summery) > 0 {
+				b.WriteString(": " + markdownEscape(hc.Summery))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(data.Help) > 0 {
+		b.WriteString(data.Help + "\n")
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+// generateDocs writes a page for the command set and each of its subcommands into the directory
+func (cs *CommandSet) generateDocs(dir string, ext string, write func(*CommandSet, io.Writer) error) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, docName(cs.commandPath())+ext))
+	if err != nil {
+		return err
+	}
+	if err := write(cs, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	for _, name := range cs.commandNames() {
+		if err := cs.Commands[name].generateDocs(dir, ext, write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// docName returns the command path as a file or page name. i.e.: app remote add => app-remote-add
+func docName(path string) string {
+	return strings.Join(strings.Fields(path), "-")
+}
+
+// markdownEscape escapes characters with inline meaning in Markdown
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;").Replace(s)
+}
+
+// roffEscape escapes backslashes and hyphens, and control characters at the start of a line
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffParagraphs returns the text as roff paragraphs separated by blank lines in the text
+func roffParagraphs(text string) string {
+	b := &strings.Builder{}
+	for i, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if i > 0 {
+			b.WriteString(".PP\n")
+		}
+		b.WriteString(roffEscape(strings.TrimSpace(paragraph)) + "\n")
+	}
+	return b.String()
+}
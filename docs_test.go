@@ -0,0 +1,208 @@
+package cliopatra
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newDocsApp returns an app with a subcommand and a nested subcommand for generated pages
+func newDocsApp(t *testing.T) *Cliopatra {
+	t.Helper()
+	c, err := New(CommandSet{Name: "app", Summery: "Copy files", Description: "Copies -files.\n\nSecond paragraph.", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddFlag("force", []string{"f", "force"}, nil, "Overwrite existing files")
+	o, _ := c.AddOption("output", []string{"o", "output"}, nil, "The output file")
+	o.SetRequired(true)
+	c.AddArgument("source", []string{"SOURCE"}, "The source")
+	r, _ := c.AddCommand(CommandSet{Name: "remote", Summery: "Manage remotes"})
+	r.AddCommand(CommandSet{Name: "add", Summery: "Add a remote"})
+	return c
+}
+
+func TestWriteMan(t *testing.T) {
+	c := newDocsApp(t)
+	tests := []struct {
+		name    string
+		cs      *CommandSet
+		section string
+		want    string
+	}{
+		{"root", c.CommandSet, "", `.TH "APP" "1"
+.SH NAME
+app \- Copy files
+.SH SYNOPSIS
+.B app
+[\-h] [\-f] \-o OUTPUT [SOURCE] COMMAND
+.SH DESCRIPTION
+Copies \-files.
+.PP
+Second paragraph.
+.SH OPTIONS
+.TP
+.B \-h, \-\-help
+Show this help and exit
+.TP
+.B \-f, \-\-force
+Overwrite existing files
+.TP
+.B \-o, \-\-output
+The output file (required)
+.SH ARGUMENTS
+.TP
+.I SOURCE
+The source
+.SH COMMANDS
+.TP
+.B remote
+Manage remotes
+.SH SEE ALSO
+.BR app\-remote (1)
+`},
+		{"subcommand", c.GetCommand("remote"), "8", `.TH "APP-REMOTE" "8"
+.SH NAME
+app\-remote \- Manage remotes
+.SH SYNOPSIS
+.B app remote
+[\-h] COMMAND
+.SH OPTIONS
+.TP
+.B \-h, \-\-help
+Show this help and exit
+.SH COMMANDS
+.TP
+.B add
+Add a remote
+.SH SEE ALSO
+.BR app (8),
+.BR app\-remote\-add (8)
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &strings.Builder{}
+			if err := tt.cs.WriteMan(b, tt.section); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("WriteMan() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	c := newDocsApp(t)
+	tests := []struct {
+		name string
+		cs   *CommandSet
+		want string
+	}{
+		{"root", c.CommandSet, "# app\n\nCopy files\n\n## Usage\n\n```\napp [-h] [-f] -o OUTPUT [SOURCE] COMMAND\n```\n\n" +
+			"Copies -files.\n\nSecond paragraph.\n\n" +
+			"## Flags\n\n- `-h`, `--help`: Show this help and exit\n- `-f`, `--force`: Overwrite existing files\n\n" +
+			"## Options\n\n- `-o`, `--output`: The output file (required)\n\n" +
+			"## Arguments\n\n- `SOURCE`: The source\n\n" +
+			"## Commands\n\n- [remote](app-remote.md): Manage remotes\n"},
+		{"subcommand", c.GetCommand("remote"), "# app remote\n\nManage remotes\n\n## Usage\n\n```\napp remote [-h] COMMAND\n```\n\n" +
+			"## Flags\n\n- `-h`, `--help`: Show this help and exit\n\n" +
+			"## Commands\n\n- [add](app-remote-add.md): Add a remote\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &strings.Builder{}
+			if err := tt.cs.WriteMarkdown(b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("WriteMarkdown() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateDocs(t *testing.T) {
+	c := newDocsApp(t)
+	tests := []struct {
+		name     string
+		generate func(dir string) error
+		want     []string
+	}{
+		{"man default section", func(dir string) error { return c.GenerateMan(dir, "") }, []string{"app-remote-add.1", "app-remote.1", "app.1"}},
+		{"man section", func(dir string) error { return c.GenerateMan(dir, "8") }, []string{"app-remote-add.8", "app-remote.8", "app.8"}},
+		{"markdown", c.GenerateMarkdown, []string{"app-remote-add.md", "app-remote.md", "app.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "docs")
+			if err := tt.generate(dir); err != nil {
+				t.Fatal(err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	if err := c.GenerateMan(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	b := &strings.Builder{}
+	c.GetCommand("remote").WriteMan(b, "")
+	if got, _ := os.ReadFile(filepath.Join(dir, "app-remote.1")); string(got) != b.String() {
+		t.Errorf("app-remote.1 =\n%s\nwant\n%s", got, b.String())
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"-f", `\-f`},
+		{`C:\dir`, `C:\edir`},
+		{".hidden", `\&.hidden`},
+		{"it's\n'quoted", "it's\n\\&'quoted"},
+	}
+
+	for _, tt := range tests {
+		if got := roffEscape(tt.in); got != tt.want {
+			t.Errorf("roffEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"*bold* _em_", `\*bold\* \_em\_`},
+		{"`code` <tag>", "\\`code\\` &lt;tag>"},
+	}
+
+	for _, tt := range tests {
+		if got := markdownEscape(tt.in); got != tt.want {
+			t.Errorf("markdownEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cliopatra
+
+import (
+	"os"
+	"strings"
+)
+
+// SetEnvPrefix defines the prefix added to the environment variable names of the command set's parameters. i.e.: MYAPP_
+// Subcommands without a prefix of their own use the prefix of their nearest parent.
+func (cs *CommandSet) SetEnvPrefix(prefix string) {
+	cs.EnvPrefix = prefix
+}
+
+// envPrefix returns the environment variable prefix for the command set or its nearest parent with one
+func (cs CommandSet) envPrefix() string {
+	if len(cs.EnvPrefix) > 0 || cs.parent == nil {
+		return cs.EnvPrefix
+	}
+	return cs.parent.envPrefix()
+}
+
+// envHelp returns the environment variable names for the parameter formatted for help output
+func (cs CommandSet) envHelp(pv CommandLineParameter) string {
+	names := pv.GetEnv()
+	if len(names) == 0 {
+		return ""
+	}
+	prefix := cs.envPrefix()
+	full := make([]string, len(names))
+	for i, name := range names {
+		full[i] = prefix + name
+	}
+	return " [env: " + strings.Join(full, ", ") + "]"
+}
+
+// loadEnv applies environment variable defaults to the parameters of the command set and its subcommands
+// that were not given on the command line. The first variable defined for each parameter is used.
+func (cs *CommandSet) loadEnv() {
+	prefix := cs.envPrefix()
+	for _, pv := range cs.Parameters {
+		if isSet(pv) {
+			continue
+		}
+		for _, name := range pv.GetEnv() {
+			if v, ok := os.LookupEnv(prefix + name); ok {
+				setEnvDefault(pv, v)
+				break
+			}
+		}
+	}
+	for _, sub := range cs.Commands {
+		sub.loadEnv()
+	}
+}
+
+// setEnvDefault stores the environment variable value for the parameter and resolves its current value
+func setEnvDefault(pv CommandLineParameter, v string) {
+	p := parameterOf(pv)
+	p.envDefault, p.defaultSet = v, true
+	resolveDefault(pv)
+}
@@ -0,0 +1,55 @@
+package cliopatra
+
+import (
+	"testing"
+)
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want map[string]string
+		url  string
+	}{
+		{"unset", nil, nil, map[string]string{"output": "default.txt", "all": "false", "verbose": "0"}, ""},
+		{"prefixed", map[string]string{"APP_OUTPUT": "env.txt", "APP_ALL": "yes", "APP_VERBOSE": "2"}, nil, map[string]string{"output": "env.txt", "all": "true", "verbose": "2"}, ""},
+		{"not prefixed", map[string]string{"OUTPUT": "env.txt"}, nil, map[string]string{"output": "default.txt"}, ""},
+		{"first name", map[string]string{"APP_OUT": "out", "APP_OUTPUT": "output"}, nil, map[string]string{"output": "output"}, ""},
+		{"second name", map[string]string{"APP_OUT": "out"}, nil, map[string]string{"output": "out"}, ""},
+		{"command line over env", map[string]string{"APP_OUTPUT": "env.txt", "APP_VERBOSE": "2"}, []string{"--output", "cli.txt", "-v"}, map[string]string{"output": "cli.txt", "verbose": "1"}, ""},
+		{"subcommand parent prefix", map[string]string{"APP_URL": "parent"}, []string{"remote"}, nil, "parent"},
+		{"subcommand own prefix", map[string]string{"APP_URL": "parent", "REMOTE_URL": "own"}, []string{"other"}, nil, "own"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}, EnvPrefix: "APP_"})
+			c.Parameters["output"].SetDefault("default.txt")
+			c.Parameters["output"].SetEnv([]string{"OUTPUT", "OUT"})
+			c.Parameters["all"].SetEnv([]string{"ALL"})
+			c.Parameters["verbose"].SetEnv([]string{"VERBOSE"})
+			remote, _ := c.AddCommand(CommandSet{Name: "remote"})
+			other, _ := c.AddCommand(CommandSet{Name: "other", EnvPrefix: "REMOTE_"})
+			for _, sub := range []*CommandSet{remote, other} {
+				url, _ := sub.AddOption("url", []string{"url"}, nil, "The remote URL")
+				url.SetEnv([]string{"URL"})
+			}
+
+			checkErrors(t, c.Parse(tt.args), nil)
+			checkValues(t, c.CommandSet, tt.want)
+			if c.Command != c.CommandSet {
+				checkValues(t, c.Command, map[string]string{"url": tt.url})
+			}
+		})
+	}
+
+	c, _ := New(CommandSet{Name: "app"})
+	o, _ := c.AddOption("name", []string{"name"}, nil, "The name")
+	if err := o.SetEnv([]string{" "}); err == nil {
+		t.Errorf("SetEnv(blank) = nil, want an error")
+	}
+}
@@ -0,0 +1,213 @@
+package cliopatra
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	ErrorParserMissing = "no parser registered for the type"
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	ErrParserMissing = errors.New(ErrorParserMissing) // Returned by AddOptionOf and AddArgumentOf for types without a parser
+	parsers          = map[reflect.Type]interface{}{
+		reflect.TypeOf(""):               ParserFunc[string](func(s string) (string, error) { return s, nil }),
+		reflect.TypeOf(false):            ParserFunc[bool](strconv.ParseBool),
+		reflect.TypeOf(0):                ParserFunc[int](strconv.Atoi),
+		reflect.TypeOf(int64(0)):         ParserFunc[int64](func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }),
+		reflect.TypeOf(uint(0)):          ParserFunc[uint](func(s string) (uint, error) { u, err := strconv.ParseUint(s, 10, intSize); return uint(u), err }),
+		reflect.TypeOf(uint64(0)):        ParserFunc[uint64](func(s string) (uint64, error) { return strconv.ParseUint(s, 10, 64) }),
+		reflect.TypeOf(float64(0)):       ParserFunc[float64](func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }),
+		reflect.TypeOf(time.Duration(0)): ParserFunc[time.Duration](time.ParseDuration),
+	}
+	parsersMu sync.RWMutex
+)
+
+/*
+ * TYPES
+ */
+
+// ParserFunc converts a parameter value to the type T
+type ParserFunc[T any] func(s string) (T, error)
+
+// ArgumentOf is an Argument with a value of type T
+type ArgumentOf[T any] struct {
+	*Argument
+	value *genericValue[T]
+}
+
+// Get returns the value converted at parse time. The zero value if the argument was not given and has no default.
+func (a *ArgumentOf[T]) Get() T {
+	return a.value.v
+}
+
+// OptionOf is an Option with a value of type T
+type OptionOf[T any] struct {
+	*Option
+	value *genericValue[T]
+}
+
+// Get returns the value converted at parse time. The zero value if the option was not given and has no default.
+func (o *OptionOf[T]) Get() T {
+	return o.value.v
+}
+
+// SliceOptionOf is a repeatable Option with values of type T
+type SliceOptionOf[T any] struct {
+	*Option
+	value *sliceValue[T]
+}
+
+// Get returns the values converted at parse time. Nil if the option was not given and has no default.
+func (o *SliceOptionOf[T]) Get() []T {
+	return o.value.v
+}
+
+// genericValue is the Value of an ArgumentOf or OptionOf
+type genericValue[T any] struct {
+	parse ParserFunc[T]
+	s     string
+	v     T
+}
+
+// reset clears the value between parses
+func (g *genericValue[T]) reset() {
+	var zero T
+	g.s = ""
+	g.v = zero
+}
+
+func (g *genericValue[T]) Set(s string) error {
+	v, err := g.parse(s)
+	if err != nil {
+		return err
+	}
+	g.s = s
+	g.v = v
+	return nil
+}
+
+func (g *genericValue[T]) String() string {
+	return g.s
+}
+
+// sliceValue is the Value of a SliceOptionOf
+type sliceValue[T any] struct {
+	parse ParserFunc[T]
+	s     []string
+	v     []T
+}
+
+// reset clears the values between parses
+func (sv *sliceValue[T]) reset() {
+	sv.s = nil
+	sv.v = nil
+}
+
+func (sv *sliceValue[T]) Set(s string) error {
+	v, err := sv.parse(s)
+	if err != nil {
+		return err
+	}
+	sv.s = append(sv.s, s)
+	sv.v = append(sv.v, v)
+	return nil
+}
+
+func (sv *sliceValue[T]) String() string {
+	return strings.Join(sv.s, ",")
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// AddArgumentOf adds an argument with a value of type T to the command set
+func AddArgumentOf[T any](cs *CommandSet, key string, name []string, help string) (*ArgumentOf[T], error) {
+	parse, err := parserOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	a, err := cs.AddArgument(key, name, help)
+	if err != nil {
+		return nil, err
+	}
+	value := &genericValue[T]{parse: parse}
+	a.SetValueType(value)
+	return &ArgumentOf[T]{a, value}, nil
+}
+
+// AddOptionOf adds an option with a value of type T to the command set
+func AddOptionOf[T any](cs *CommandSet, key string, name []string, prefix *[]string, help string) (*OptionOf[T], error) {
+	parse, err := parserOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	o, err := cs.AddOption(key, name, prefix, help)
+	if err != nil {
+		return nil, err
+	}
+	value := &genericValue[T]{parse: parse}
+	o.SetValueType(value)
+	return &OptionOf[T]{o, value}, nil
+}
+
+// AddSliceOptionOf adds a repeatable option with values of type T to the command set
+func AddSliceOptionOf[T any](cs *CommandSet, key string, name []string, prefix *[]string, help string) (*SliceOptionOf[T], error) {
+	parse, err := parserOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	o, err := cs.AddOption(key, name, prefix, help)
+	if err != nil {
+		return nil, err
+	}
+	value := &sliceValue[T]{parse: parse}
+	o.SetRepeatable(true)
+	o.SetValueType(value)
+	return &SliceOptionOf[T]{o, value}, nil
+}
+
+// RegisterParser sets the parser used for the type T by AddOptionOf and AddArgumentOf, replacing any
+// previous parser for the type. Parsers for string, bool, int, int64, uint, uint64, float64 and
+// time.Duration are built in. The built-in integer parsers read base 10, so 010 is ten.
+func RegisterParser[T any](f ParserFunc[T]) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[reflect.TypeOf((*T)(nil)).Elem()] = f
+}
+
+// parserOf returns the registered parser for the type T. Types implementing encoding.TextUnmarshaler
+// without a registered parser use UnmarshalText.
+func parserOf[T any]() (ParserFunc[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	parsersMu.RLock()
+	f, ok := parsers[t]
+	parsersMu.RUnlock()
+	if ok {
+		return f.(ParserFunc[T]), nil
+	}
+
+	var zero T
+	if _, ok := interface{}(&zero).(encoding.TextUnmarshaler); ok {
+		return func(s string) (T, error) {
+			var v T
+			err := interface{}(&v).(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			return v, err
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrParserMissing, t)
+}
@@ -0,0 +1,101 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// parseWith parses the value with the option type T and returns the converted value as text
+func parseWith[T any](t *testing.T, s string) (string, error) {
+	t.Helper()
+	c, err := New(CommandSet{Name: "app", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := AddOptionOf[T](c.CommandSet, "value", []string{"value"}, nil, "The value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Parse([]string{"--value", s}); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(o.Get()), nil
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(t *testing.T, s string) (string, error)
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"string", parseWith[string], "010", "010", false},
+		{"bool", parseWith[bool], "true", "true", false},
+		{"bool invalid", parseWith[bool], "yes", "", true},
+		{"int", parseWith[int], "010", "10", false},
+		{"int negative", parseWith[int], "-7", "-7", false},
+		{"int hex", parseWith[int], "0x10", "", true},
+		{"int64", parseWith[int64], "010", "10", false},
+		{"int64 hex", parseWith[int64], "0x10", "", true},
+		{"uint", parseWith[uint], "010", "10", false},
+		{"uint binary", parseWith[uint], "0b10", "", true},
+		{"uint negative", parseWith[uint], "-1", "", true},
+		{"uint64", parseWith[uint64], "010", "10", false},
+		{"uint64 octal", parseWith[uint64], "0o10", "", true},
+		{"float64", parseWith[float64], "1.5", "1.5", false},
+		{"duration", parseWith[time.Duration], "90s", "1m30s", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(t, tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if err != nil {
+				checkErrors(t, err, []error{ErrConversion})
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserMissing(t *testing.T) {
+	c, err := New(CommandSet{Name: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddOptionOf[complex128](c.CommandSet, "value", []string{"value"}, nil, "The value"); errors.Is(err, ErrParserMissing) == false {
+		t.Errorf("AddOptionOf[complex128]() error = %v, want %v", err, ErrParserMissing)
+	}
+}
+
+// TestOptionOfReset parses twice, the second parse restoring the zero value of the typed option
+func TestOptionOfReset(t *testing.T) {
+	c, err := New(CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := AddOptionOf[int](c.CommandSet, "size", []string{"size"}, nil, "The size")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Parse([]string{"--size", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if size.Get() != 3 {
+		t.Errorf("size = %d, want 3", size.Get())
+	}
+	if err := c.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if size.Get() != 0 {
+		t.Errorf("size after re-parse = %d, want 0", size.Get())
+	}
+}
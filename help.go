@@ -0,0 +1,525 @@
+package cliopatra
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	DefaultHelpWidth = 80 // The help width used when the terminal width is unknown
+	helpIndent       = 2  // Indent of help section content
+	helpLabelMax     = 28 // Labels wider than this are written on their own line
+	helpWidthMin     = 40 // The narrowest width help is wrapped to
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+
+// DefaultHelpTemplate is the text/template used for help output unless a command set or one of its parents
+// defines its own. The template data is a HelpData. The functions available are:
+//
+//	columns WIDTH ENTRIES        two column list of HelpParameter or HelpCommand labels and text
+//	join LIST SEPARATOR          strings.Join
+//	upper TEXT                   strings.ToUpper
+//	wrap WIDTH INDENT HANGING TEXT  text wrapped to the width with the first and following lines indented
+var DefaultHelpTemplate = `{{wrap .Width 0 0 .Title}}
+USAGE:
+{{wrap .Width 2 4 .Usage}}
+{{- with .Description}}
+DESCRIPTION:
+{{wrap $.Width 2 2 .}}
+{{- end}}
+{{- with .Flags}}
+FLAGS:
+{{columns $.Width .}}
+{{- end}}
+{{- with .Options}}
+OPTIONS:
+{{columns $.Width .}}
+{{- end}}
+{{- with .Arguments}}
+ARGUMENTS:
+{{columns $.Width .}}
+{{- end}}
+{{- with .Commands}}
+COMMANDS:
+{{columns $.Width .}}
+{{- end}}
+{{- with .Help}}
+{{wrap $.Width 0 0 .}}
+{{- end}}`
+
+/*
+ * TYPES
+ */
+
+// HelpCommand is the help template data for a subcommand
+type HelpCommand struct {
+	Name    string // The name of the subcommand
+	Summery string // The short description of the subcommand
+}
+
+// HelpData is the help template data for a command set
+type HelpData struct {
+	Arguments   []HelpParameter // The arguments in definition order
+	Commands    []HelpCommand   // The subcommands in definition order
+	Description string          // The long description of the command set
+	Flags       []HelpParameter // The flags in definition order, followed by inherited flags
+	Help        string          // The help information of the command set
+	Name        string          // The name of the command set
+	Options     []HelpParameter // The options in definition order, followed by inherited options
+	Parameters  []HelpParameter // All parameters in definition order, followed by inherited parameters
+	Path        string          // The names of the command set and its parents. i.e.: app remote add
+	Summery     string          // The short description of the command set
+	Title       string          // The path and summery. i.e.: app remote add - Add a remote
+	Usage       string          // The usage synopsis
+	Width       int             // The width to wrap help output to
+}
+
+// HelpParameter is the help template data for a parameter
+type HelpParameter struct {
+	Default    string   // The default value
+	Env        []string // The environment variable names including the command set prefix
+	HasDefault bool     // If a default value is defined
+	Help       string   // The help information of the parameter
+	Inherited  bool     // If the parameter is inherited from a parent command set
+	Key        string   // The parameter key
+	Kind       string   // Flag, Option or Argument
+	Label      string   // The names with their prefixes as shown in the default help. i.e.: -v | --verbose
+	Names      []string // The command line names
+	Prefixes   []string // The prefixes allowed
+	Required   bool     // If the parameter is required
+	Text       string   // The help with default, required and environment variable notes
+}
+
+// GetHelp returns the help info for the command set rendered with its help template. Parameters are listed
+// in definition order grouped into flags, options, arguments and commands, wrapped to the help width.
+func (cs CommandSet) GetHelp() string {
+	b := &strings.Builder{}
+	if err := cs.WriteHelp(b); err != nil {
+		b.Reset()
+		template.Must(newHelpTemplate(DefaultHelpTemplate)).Execute(b, cs.HelpData())
+	}
+	return b.String()
+}
+
+// HelpData returns the help template data for the command set
+func (cs CommandSet) HelpData() HelpData {
+	data := HelpData{
+		Description: cs.Description,
+		Help:        cs.Help,
+		Name:        cs.Name,
+		Path:        cs.commandPath(),
+		Summery:     cs.Summery,
+		Usage:       cs.Usage(),
+		Width:       cs.helpWidth(),
+	}
+	data.Title = data.Path
+	if len(cs.Summery) > 0 {
+		data.Title += " - " + cs.Summery
+	}
+
+	for _, pk := range cs.helpKeys() {
+		hp := cs.helpParameter(pk)
+		data.Parameters = append(data.Parameters, hp)
+		switch hp.Kind {
+		case "Flag":
+			data.Flags = append(data.Flags, hp)
+		case "Option":
+			data.Options = append(data.Options, hp)
+		case "Argument":
+			data.Arguments = append(data.Arguments, hp)
+		}
+	}
+	for _, name := range cs.commandNames() {
+		data.Commands = append(data.Commands, HelpCommand{Name: name, Summery: cs.Commands[name].Summery})
+	}
+
+	return data
+}
+
+// SetHelpTemplate defines the text/template used for the help output of the command set and its subcommands.
+// See DefaultHelpTemplate for the data and functions available.
+func (cs *CommandSet) SetHelpTemplate(text string) error {
+	if _, err := newHelpTemplate(text); err != nil {
+		return err
+	}
+	cs.HelpTemplate = text
+	return nil
+}
+
+// WriteHelp writes the help info for the command set rendered with its help template
+func (cs CommandSet) WriteHelp(w io.Writer) error {
+	t, err := newHelpTemplate(cs.helpTemplate())
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, cs.HelpData())
+}
+
+// Usage returns the usage synopsis of the command set generated from its parameter definitions.
+// Arguments are listed in the order they are assigned, those with a fixed Position first.
+// i.e.: app build [-v] --output OUTPUT SOURCE... [DEST]
+func (cs CommandSet) Usage() string {
+	parts := []string{cs.commandPath()}
+	arguments := []string{}
+	fixed := []*Argument{}
+	fixedNames := map[*Argument]string{}
+
+	for _, pk := range cs.helpKeys() {
+		pv := cs.lookupParameter(pk)
+		p := parameterOf(pv)
+		name := strings.SplitN(cs.helpLabel(pk, pv), " | ", 2)[0]
+		switch t := pv.(type) {
+		case *Flag:
+			if t.IsRequired == false {
+				name = "[" + name + "]"
+			}
+			if t.counter {
+				name += "..."
+			}
+			parts = append(parts, name)
+		case *Option:
+			value := strings.ToUpper(pk)
+			if t.valueRequired {
+				name += " " + value
+			} else {
+				name += "[" + GNUValueSeparator + value + "]"
+			}
+			if p.IsRequired == false {
+				name = "[" + name + "]"
+			}
+			if t.repeatable {
+				name += "..."
+			}
+			parts = append(parts, name)
+		case *Argument:
+			min, max := t.arityRange(1)
+			switch {
+			case t.arity == ArityVariadic:
+				name += "..."
+			case max > 1:
+				name = strings.TrimSpace(strings.Repeat(name+" ", max))
+			}
+			if min == 0 || t.IsRequired == false {
+				name = "[" + name + "]"
+			}
+			if t.Position > 0 {
+				fixed = append(fixed, t)
+				fixedNames[t] = name
+				continue
+			}
+			arguments = append(arguments, name)
+		}
+	}
+
+	sort.SliceStable(fixed, func(i, j int) bool { return fixed[i].Position < fixed[j].Position })
+	for _, a := range fixed {
+		parts = append(parts, fixedNames[a])
+	}
+	parts = append(parts, arguments...)
+	if len(cs.Commands) > 0 {
+		parts = append(parts, "COMMAND")
+	}
+	return strings.Join(parts, " ")
+}
+
+// commandNames returns the subcommand names in definition order
+func (cs CommandSet) commandNames() []string {
+	names := make([]string, 0, len(cs.Commands))
+	seen := map[string]bool{}
+	for _, name := range cs.commandOrder {
+		if _, ok := cs.Commands[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	rest := []string{}
+	for name := range cs.Commands {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// commandPath returns the names of the command set and its parents. i.e.: app remote add
+func (cs CommandSet) commandPath() string {
+	name := cs.Name
+	if cs.parent == nil && len(name) == 0 {
+		name = filepath.Base(os.Args[0])
+	}
+	if cs.parent == nil {
+		return name
+	}
+	return cs.parent.commandPath() + " " + name
+}
+
+// helpKeys returns the keys of the parameters of the command set followed by the inherited parameters
+func (cs CommandSet) helpKeys() []string {
+	_, inherited := cs.inheritedParameters()
+	return append(cs.parameterKeys(), inherited...)
+}
+
+// helpLabel returns the command line names of the parameter for help output. Arguments use their display
+// names, or the upper case key if unnamed, as they are matched by position.
+func (cs CommandSet) helpLabel(pk string, pv CommandLineParameter) string {
+	if _, ok := pv.(*Argument); ok {
+		if len(pv.GetName()) == 0 {
+			return strings.ToUpper(pk)
+		}
+		return strings.Join(pv.GetName(), " | ")
+	}
+
+	return strings.Join(cs.parameterLabels(pv), " | ")
+}
+
+// helpParameter returns the help template data for the parameter
+func (cs CommandSet) helpParameter(pk string) HelpParameter {
+	pv := cs.lookupParameter(pk)
+	p := parameterOf(pv)
+	_, own := cs.Parameters[pk]
+	hp := HelpParameter{
+		Help:      pv.GetHelp(),
+		Inherited: !own,
+		Key:       pk,
+		Label:     cs.helpLabel(pk, pv),
+		Names:     pv.GetName(),
+		Prefixes:  pv.GetPrefix(),
+		Required:  p.IsRequired,
+		Text:      cs.helpText(pv),
+	}
+	for _, name := range pv.GetEnv() {
+		hp.Env = append(hp.Env, cs.envPrefix()+name)
+	}
+	switch t := pv.(type) {
+	case *Flag:
+		hp.Kind = "Flag"
+		hp.HasDefault = t.hasDefault
+		hp.Default = strconv.FormatBool(t.defaultValue)
+		if t.counter {
+			hp.Default = strconv.Itoa(t.defaultCount)
+		}
+	case *Option:
+		hp.Kind = "Option"
+		hp.HasDefault = t.hasDefault
+		hp.Default = t.defaultValue
+	case *Argument:
+		hp.Kind = "Argument"
+		hp.HasDefault = t.hasDefault
+		hp.Default = t.defaultValue
+	}
+	return hp
+}
+
+// helpTemplate returns the help template of the command set or its nearest parent with one
+func (cs CommandSet) helpTemplate() string {
+	if len(cs.HelpTemplate) > 0 {
+		return cs.HelpTemplate
+	}
+	if cs.parent != nil {
+		return cs.parent.helpTemplate()
+	}
+	return DefaultHelpTemplate
+}
+
+// helpText returns the help text of the parameter with its default, required and environment annotations
+func (cs CommandSet) helpText(pv CommandLineParameter) string {
+	p := parameterOf(pv)
+	text := pv.GetHelp()
+	if len(text) == 0 {
+		text = p.Summery
+	}
+
+	notes := []string{}
+	switch t := pv.(type) {
+	case *Flag:
+		if t.counter && t.defaultCount != 0 {
+			notes = append(notes, "(default: "+strconv.Itoa(t.defaultCount)+")")
+		} else if t.counter == false && t.defaultValue {
+			notes = append(notes, "(default: true)")
+		}
+	case *Option:
+		if t.hasDefault {
+			notes = append(notes, "(default: "+strconv.Quote(t.defaultValue)+")")
+		}
+	case *Argument:
+		if t.hasDefault {
+			notes = append(notes, "(default: "+strconv.Quote(t.defaultValue)+")")
+		}
+	}
+	if p.IsRequired {
+		notes = append(notes, "(required)")
+	}
+	if env := strings.TrimSpace(cs.envHelp(pv)); len(env) > 0 {
+		notes = append(notes, env)
+	}
+
+	return strings.TrimSpace(text + " " + strings.Join(notes, " "))
+}
+
+// helpWidth returns the width to wrap help output to. The terminal width of stdout is used if none is
+// defined. COLUMNS is only a fallback as shells do not usually export it to child processes.
+func (cs CommandSet) helpWidth() int {
+	width := cs.HelpWidth
+	if width <= 0 && cs.parent != nil {
+		width = cs.parent.helpWidth()
+	}
+	if width <= 0 {
+		width = terminalWidth()
+	}
+	if width <= 0 {
+		if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil {
+			width = columns
+		}
+	}
+	if width <= 0 {
+		width = DefaultHelpWidth
+	}
+	if width < helpWidthMin {
+		width = helpWidthMin
+	}
+	return width
+}
+
+// lookupParameter returns the parameter of the command set or the inherited parameter with the key
+func (cs CommandSet) lookupParameter(key string) CommandLineParameter {
+	if pv, ok := cs.Parameters[key]; ok {
+		return pv
+	}
+	inherited, _ := cs.inheritedParameters()
+	return inherited[key]
+}
+
+// parameterLabels returns the prefixed names of a Flag or Option as shown to the user
+func (cs CommandSet) parameterLabels(pv CommandLineParameter) []string {
+	labels := []string{}
+	for _, v := range pv.GetName() {
+		prefixes := cs.parameterPrefixes(pv, v)
+		// With both hyphen prefixes allowed, show the POSIX form of letter names and the GNU form of word names
+		if containsString(prefixes, GNUPrefix) && containsString(prefixes, PosixPrefix) {
+			if len(v) == 1 {
+				labels = append(labels, PosixPrefix+v)
+			} else {
+				labels = append(labels, GNUPrefix+v)
+			}
+			continue
+		}
+		for _, p := range prefixes {
+			labels = append(labels, p+v)
+		}
+	}
+	return labels
+}
+
+/*
+ * FUNCTIONS
+ */
+
+// wrapText splits the text into lines no wider than width. Existing line breaks are kept.
+func wrapText(text string, width int) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, word := range words[1:] {
+			if len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line += " " + word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// helpColumns returns the entries as two columns with the text wrapped beside the labels. Entries may be
+// a []HelpParameter or a []HelpCommand.
+func helpColumns(width int, entries interface{}) string {
+	labels := []string{}
+	texts := []string{}
+	switch t := entries.(type) {
+	case []HelpParameter:
+		for _, hp := range t {
+			labels = append(labels, hp.Label)
+			texts = append(texts, hp.Text)
+		}
+	case []HelpCommand:
+		for _, hc := range t {
+			labels = append(labels, hc.Name)
+			texts = append(texts, hc.Summery)
+		}
+	}
+
+	labelWidth := 0
+	for _, label := range labels {
+		if len(label) > labelWidth && len(label) <= helpLabelMax {
+			labelWidth = len(label)
+		}
+	}
+	column := helpIndent + labelWidth + 2
+	textWidth := width - column
+	if textWidth < helpWidthMin/2 {
+		textWidth = helpWidthMin / 2
+	}
+
+	b := &strings.Builder{}
+	for i, label := range labels {
+		b.WriteString(strings.Repeat(" ", helpIndent) + label)
+		if len(label) > labelWidth {
+			b.WriteString("\n" + strings.Repeat(" ", column))
+		} else {
+			b.WriteString(strings.Repeat(" ", column-helpIndent-len(label)))
+		}
+		for j, line := range wrapText(texts[i], textWidth) {
+			if j > 0 {
+				b.WriteString(strings.Repeat(" ", column))
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// helpWrap returns the text wrapped to the width with the first line and following lines indented
+func helpWrap(width int, indent int, hangingIndent int, text string) string {
+	b := &strings.Builder{}
+	for i, line := range wrapText(text, width-hangingIndent) {
+		switch {
+		case len(line) == 0:
+			// No trailing whitespace on blank lines
+		case i == 0:
+			b.WriteString(strings.Repeat(" ", indent))
+		default:
+			b.WriteString(strings.Repeat(" ", hangingIndent))
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// newHelpTemplate parses the help template text with the help template functions
+func newHelpTemplate(text string) (*template.Template, error) {
+	return template.New("help").Funcs(template.FuncMap{
+		"columns": helpColumns,
+		"join":    strings.Join,
+		"upper":   strings.ToUpper,
+		"wrap":    helpWrap,
+	}).Parse(text)
+}
@@ -0,0 +1,299 @@
+package cliopatra
+
+import (
+	"strings"
+	"testing"
+)
+
+// newHelpApp returns an app with each kind of parameter and a subcommand for help output
+func newHelpApp(t *testing.T) *Cliopatra {
+	t.Helper()
+	c, err := New(CommandSet{Name: "app", Summery: "Copy files", Description: "Copies the source to the destination.", IsGNU: true, HelpWidth: 60, EnvPrefix: "APP_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := c.AddFlag("verbose", []string{"v", "verbose"}, nil, "Verbose output")
+	v.SetCounter(true)
+	f, _ := c.AddFlag("force", []string{"f", "force"}, nil, "Overwrite existing files")
+	f.SetDefault("false")
+	o, _ := c.AddOption("output", []string{"o", "output"}, nil, "The output file")
+	o.SetRequired(true)
+	o.SetEnv([]string{"OUTPUT"})
+	m, _ := c.AddOption("mode", []string{"mode"}, nil, "The file mode")
+	m.SetDefault("0644")
+	c.SetPersistent("verbose", true)
+	a, _ := c.AddArgument("source", []string{"SOURCE"}, "The source files")
+	a.SetArity(ArityVariadic)
+	a.SetRequired(true)
+	c.AddCommand(CommandSet{Name: "remote", Summery: "Manage remotes"})
+	return c
+}
+
+func TestGetHelp(t *testing.T) {
+	c := newHelpApp(t)
+	tests := []struct {
+		name string
+		cs   *CommandSet
+		want string
+	}{
+		{"root", c.CommandSet, `app - Copy files
+
+USAGE:
+  app [-h] [-v]... [-f] -o OUTPUT [--mode MODE] SOURCE...
+    COMMAND
+
+DESCRIPTION:
+  Copies the source to the destination.
+
+FLAGS:
+  -h | --help     Show this help and exit
+  -v | --verbose  Verbose output
+  -f | --force    Overwrite existing files
+
+OPTIONS:
+  -o | --output  The output file (required) [env:
+                 APP_OUTPUT]
+  --mode         The file mode (default: "0644")
+
+ARGUMENTS:
+  SOURCE  The source files (required)
+
+COMMANDS:
+  remote  Manage remotes
+`},
+		{"subcommand", c.GetCommand("remote"), `app remote - Manage remotes
+
+USAGE:
+  app remote [-h] [-v]...
+
+FLAGS:
+  -h | --help     Show this help and exit
+  -v | --verbose  Verbose output
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cs.GetHelp(); got != tt.want {
+				t.Errorf("GetHelp() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *Cliopatra)
+		want  string
+	}{
+		{"flags", func(c *Cliopatra) {
+			c.AddFlag("all", []string{"a", "all"}, nil, "")
+			f, _ := c.AddFlag("yes", []string{"yes"}, nil, "")
+			f.SetRequired(true)
+		}, "app [-h] [-a] --yes"},
+		{"options", func(c *Cliopatra) {
+			o, _ := c.AddOption("color", []string{"color"}, nil, "")
+			o.SetValueRequired(false)
+			r, _ := c.AddOption("tag", []string{"t"}, nil, "")
+			r.SetRepeatable(true)
+		}, "app [-h] [--color[=COLOR]] [-t TAG]..."},
+		{"arity", func(c *Cliopatra) {
+			a, _ := c.AddArgument("pair", []string{"PAIR"}, "")
+			a.SetArity(2)
+			a.SetRequired(true)
+			b, _ := c.AddArgument("extra", []string{"EXTRA"}, "")
+			b.SetArity(ArityOptional)
+		}, "app [-h] PAIR PAIR [EXTRA]"},
+		{"positions", func(c *Cliopatra) {
+			rest, _ := c.AddArgument("rest", []string{"rest"}, "")
+			rest.SetArity(ArityVariadic)
+			dest, _ := c.AddArgument("dest", []string{"dest"}, "")
+			dest.SetPosition(2)
+			src, _ := c.AddArgument("src", []string{"src"}, "")
+			src.SetPosition(1)
+		}, "app [-h] [src] [dest] [rest...]"},
+		{"unnamed argument", func(c *Cliopatra) {
+			c.AddArgument("file", nil, "")
+		}, "app [-h] [FILE]"},
+		{"commands", func(c *Cliopatra) {
+			c.AddCommand(CommandSet{Name: "sub"})
+		}, "app [-h] COMMAND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(c)
+			if got := c.Usage(); got != tt.want {
+				t.Errorf("Usage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpParameter(t *testing.T) {
+	c := newHelpApp(t)
+	c.Parameters["verbose"].SetDefault("2")
+	tests := []struct {
+		key        string
+		kind       string
+		label      string
+		hasDefault bool
+		def        string
+		text       string
+	}{
+		{"help", "Flag", "-h | --help", false, "false", "Show this help and exit"},
+		{"verbose", "Flag", "-v | --verbose", true, "2", "Verbose output (default: 2)"},
+		{"force", "Flag", "-f | --force", true, "false", "Overwrite existing files"},
+		{"output", "Option", "-o | --output", false, "", "The output file (required) [env: APP_OUTPUT]"},
+		{"mode", "Option", "--mode", true, "0644", `The file mode (default: "0644")`},
+		{"source", "Argument", "SOURCE", false, "", "The source files (required)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			hp := c.helpParameter(tt.key)
+			if hp.Kind != tt.kind || hp.Label != tt.label || hp.HasDefault != tt.hasDefault || hp.Default != tt.def || hp.Text != tt.text {
+				t.Errorf("helpParameter() = %+v, want %s %q %t %q %q", hp, tt.kind, tt.label, tt.hasDefault, tt.def, tt.text)
+			}
+		})
+	}
+
+	sub := c.GetCommand("remote").HelpData()
+	if len(sub.Flags) != 2 || sub.Flags[1].Key != "verbose" || sub.Flags[1].Inherited == false {
+		t.Errorf("remote Flags = %+v, want help and inherited verbose", sub.Flags)
+	}
+}
+
+func TestHelpWidth(t *testing.T) {
+	if terminalWidth() > 0 {
+		t.Skip("stdout is a terminal")
+	}
+	tests := []struct {
+		name    string
+		width   int
+		parent  int
+		columns string
+		want    int
+	}{
+		{"default", 0, 0, "", DefaultHelpWidth},
+		{"columns", 0, 0, "100", 100},
+		{"invalid columns", 0, 0, "wide", DefaultHelpWidth},
+		{"defined", 70, 0, "100", 70},
+		{"parent", 0, 50, "100", 50},
+		{"minimum", 10, 0, "", helpWidthMin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COLUMNS", tt.columns)
+			c, err := New(CommandSet{Name: "app", HelpWidth: tt.parent})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub, _ := c.AddCommand(CommandSet{Name: "sub", HelpWidth: tt.width})
+			if got := sub.helpWidth(); got != tt.want {
+				t.Errorf("helpWidth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"one two three", 20, "one two three"},
+		{"one two three", 7, "one two|three"},
+		{"averyveryverylongword x", 5, "averyveryverylongword|x"},
+		{"", 10, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(wrapText(tt.text, tt.width), "|"); got != tt.want {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSetHelpTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		sub      string
+		want     string
+		subWant  string
+	}{
+		{"data", "{{.Path}}|{{.Name}}|{{.Summery}}|{{.Width}}", "", "app|app|Copy files|60", "app remote|remote|Manage remotes|60"},
+		{"functions", `{{upper .Name}} {{range .Flags}}{{join .Names ","}};{{end}}`, "", "APP h,help;v,verbose;f,force;", "REMOTE h,help;v,verbose;"},
+		{"wrap", `{{wrap 44 2 4 .Description}}{{wrap 40 0 0 "x"}}`, "", "  Copies the source to the destination.\nx\n", "\nx\n"},
+		{"commands", `{{columns .Width .Commands}}`, "", "  remote  Manage remotes\n", ""},
+		{"subcommand template", "{{.Name}}", "sub {{.Name}}", "app", "sub remote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newHelpApp(t)
+			if err := c.SetHelpTemplate(tt.template); err != nil {
+				t.Fatal(err)
+			}
+			remote := c.GetCommand("remote")
+			if len(tt.sub) > 0 {
+				if err := remote.SetHelpTemplate(tt.sub); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := c.GetHelp(); got != tt.want {
+				t.Errorf("GetHelp() = %q, want %q", got, tt.want)
+			}
+			if got := remote.GetHelp(); got != tt.subWant {
+				t.Errorf("remote GetHelp() = %q, want %q", got, tt.subWant)
+			}
+		})
+	}
+
+	c := newHelpApp(t)
+	if err := c.SetHelpTemplate("{{.Name"); err == nil {
+		t.Errorf("SetHelpTemplate(invalid) = nil, want an error")
+	}
+	if c.HelpTemplate != "" {
+		t.Errorf("HelpTemplate = %q after an invalid template, want it unchanged", c.HelpTemplate)
+	}
+
+	// A template failing to execute is reported by WriteHelp and GetHelp falls back to the default
+	c.HelpTemplate = "{{.Missing}}"
+	var b strings.Builder
+	if err := c.WriteHelp(&b); err == nil {
+		t.Errorf("WriteHelp() = nil, want an error")
+	}
+	if got := c.GetHelp(); !strings.HasPrefix(got, "app - Copy files\n\nUSAGE:") {
+		t.Errorf("GetHelp() = %q, want the default help", got)
+	}
+}
+
+func TestHelpColumns(t *testing.T) {
+	long := strings.Repeat("x", helpLabelMax+1)
+	tests := []struct {
+		name    string
+		entries interface{}
+		want    string
+	}{
+		{"aligned", []HelpCommand{{"a", "First"}, {"abc", "Second"}}, "  a    First\n  abc  Second\n"},
+		{"wrapped", []HelpCommand{{"a", "one two three four five six seven eight nine ten eleven twelve"}}, "  a  one two three four five six seven eight nine ten eleven\n     twelve\n"},
+		{"long label", []HelpCommand{{"a", "First"}, {long, "Second"}}, "  a  First\n  " + long + "\n     Second\n"},
+		{"parameters", []HelpParameter{{Label: "-v", Text: "Verbose"}}, "  -v  Verbose\n"},
+		{"unknown", []string{"a"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := helpColumns(60, tt.entries); got != tt.want {
+				t.Errorf("helpColumns() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package cliopatra
+
+/*
+ * CONSTANTS
+ */
+
+// Log levels from least to most verbose
+const (
+	LogOff   LogLevel = iota // No logging. The default.
+	LogError                 // Parse errors
+	LogInfo                  // Parse summaries such as the selected command set
+	LogDebug                 // Argument lists and parameter values
+	LogTrace                 // Why each argument did or did not match
+)
+
+/*
+ * TYPES
+ */
+
+// LogLevel is the verbosity of the logging done while parsing
+type LogLevel int
+
+// Logger is the interface for log output. Satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logger writes to the configured Logger for messages at or below the configured level
+type logger struct {
+	level LogLevel
+	out   Logger
+}
+
+// logf writes the message if the level is enabled. Safe to call on a nil logger.
+func (l *logger) logf(level LogLevel, format string, v ...interface{}) {
+	if l == nil || l.out == nil || level == LogOff || level > l.level {
+		return
+	}
+	l.out.Printf(format, v...)
+}
+
+// SetLogger defines the logger and level used while parsing. Logging is off by default.
+// A nil logger or LogOff disables logging.
+func (c *Cliopatra) SetLogger(out Logger, level LogLevel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = logger{level: level, out: out}
+}
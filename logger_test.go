@@ -0,0 +1,64 @@
+package cliopatra
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordLogger keeps the logged lines
+type recordLogger struct {
+	lines []string
+}
+
+func (r *recordLogger) Printf(format string, v ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, v...))
+}
+
+func TestSetLogger(t *testing.T) {
+	// A message logged at each level for the command line: --nope in.txt
+	messages := map[LogLevel]string{
+		LogError: "unknown parameter",
+		LogInfo:  `command set "app" selected`,
+		LogDebug: `001  "--nope"`,
+		LogTrace: `002  "in.txt"  positional`,
+	}
+
+	tests := []struct {
+		name   string
+		out    bool
+		level  LogLevel
+		logged []LogLevel
+	}{
+		{"off", true, LogOff, nil},
+		{"nil logger", false, LogTrace, nil},
+		{"error", true, LogError, []LogLevel{LogError}},
+		{"info", true, LogInfo, []LogLevel{LogError, LogInfo}},
+		{"debug", true, LogDebug, []LogLevel{LogError, LogInfo, LogDebug}},
+		{"trace", true, LogTrace, []LogLevel{LogError, LogInfo, LogDebug, LogTrace}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestApp(t, CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+			r := &recordLogger{}
+			if tt.out {
+				c.SetLogger(r, tt.level)
+			} else {
+				c.SetLogger(nil, tt.level)
+			}
+			c.Parse([]string{"--nope", "in.txt"})
+
+			text := strings.Join(r.lines, "\n")
+			for level, message := range messages {
+				want := false
+				for _, l := range tt.logged {
+					want = want || l == level
+				}
+				if got := strings.Contains(text, message); got != want {
+					t.Errorf("level %d message %q logged = %t, want %t", level, message, got, want)
+				}
+			}
+		})
+	}
+}
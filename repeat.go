@@ -0,0 +1,128 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	ErrorMapEntry   = "a key=value pair is required"
+	ErrorValueCount = "the number of values is out of range"
+	MapSeparator    = "=" // Separates the key and value of a map option entry. i.e.: -D name=value
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	ErrValueCount = errors.New(ErrorValueCount) // A repeatable option was given too few or too many values
+)
+
+/*
+ * TYPES
+ */
+
+// GetMap returns the key=value entries of the option as a map. Later entries replace earlier ones
+// with the same key.
+func (o *Option) GetMap() (map[string]string, error) {
+	m := map[string]string{}
+	for _, entry := range o.GetValues() {
+		kv := strings.SplitN(entry, MapSeparator, 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return m, &ParseError{Cause: errors.New(ErrorMapEntry), Err: ErrConversion, Index: o.Index, Key: o.Key, Value: entry}
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// GetValues returns every value of a repeatable option in command line order, or the single value of
+// other options. Environment, config and default values are split by the separator if one is defined.
+func (o *Option) GetValues() []string {
+	if o.valueSet && o.repeatable {
+		return o.values
+	}
+	s, err := o.GetValue()
+	if err != nil || o.repeatable && len(s) == 0 {
+		return nil
+	}
+	if o.valueSet == false && o.repeatable {
+		return o.splitValue(s)
+	}
+	return []string{s}
+}
+
+// SetCount defines the minimum and maximum number of values of a repeatable option. A max of zero is
+// unlimited. Counts out of range are reported as a *ParseError with ErrValueCount.
+func (o *Option) SetCount(min int, max int) {
+	o.minCount = min
+	o.maxCount = max
+	o.repeatable = true
+}
+
+// SetMap defines the option as a repeatable map option taking key=value entries. i.e.: -D name=value
+func (o *Option) SetMap(b bool) {
+	o.isMap = b
+	if b {
+		o.repeatable = true
+	}
+}
+
+// SetRepeatable defines if each occurrence of the option adds to its values instead of replacing the value.
+// i.e.: -I a -I b
+func (o *Option) SetRepeatable(b bool) {
+	o.repeatable = b
+}
+
+// SetSeparator defines a separator splitting each value of a repeatable option into several values.
+// i.e.: "," for -I a,b
+func (o *Option) SetSeparator(sep string) {
+	o.separator = sep
+	o.repeatable = true
+}
+
+// splitValue splits the value by the separator of the option
+func (o *Option) splitValue(s string) []string {
+	if len(o.separator) == 0 {
+		return []string{s}
+	}
+	return strings.Split(s, o.separator)
+}
+
+// checkValues reports the repeatable options of the command set and its parents with a number of values
+// out of range, or map entries without a key.
+func (cs *CommandSet) checkValues() error {
+	var errs ParseErrors
+	for set := cs; set != nil; set = set.parent {
+		for _, pk := range set.parameterKeys() {
+			o, ok := set.Parameters[pk].(*Option)
+			if !ok || o.repeatable == false {
+				continue
+			}
+			// Map entries of typed options are reported by setTypedValues
+			if o.isMap && o.typed == nil {
+				if _, err := o.GetMap(); err != nil {
+					errs = errs.append(err)
+					continue
+				}
+			}
+			count := len(o.GetValues())
+			// A required option not given at all is reported by checkRequired
+			if count == 0 && o.IsRequired {
+				continue
+			}
+			if count < o.minCount || o.maxCount > 0 && count > o.maxCount {
+				cause := fmt.Errorf("%d given, at least %d", count, o.minCount)
+				if o.maxCount > 0 {
+					cause = fmt.Errorf("%d given, %d to %d allowed", count, o.minCount, o.maxCount)
+				}
+				errs = append(errs, &ParseError{Cause: cause, Err: ErrValueCount, Key: pk})
+			}
+		}
+	}
+	return errs.err()
+}
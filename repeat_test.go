@@ -0,0 +1,51 @@
+package cliopatra
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRepeatable(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		tags   []string
+		labels map[string]string
+		errs   []error
+	}{
+		{"none", nil, nil, map[string]string{}, nil},
+		{"repeated", []string{"--tag", "a", "--tag=b"}, []string{"a", "b"}, map[string]string{}, nil},
+		{"separated", []string{"--tag", "a,b", "--tag", "c"}, []string{"a", "b", "c"}, map[string]string{}, nil},
+		{"too many", []string{"--tag", "a,b,c,d"}, nil, nil, []error{ErrValueCount}},
+		{"map", []string{"--label", "env=prod", "--label", "tier=web"}, nil, map[string]string{"env": "prod", "tier": "web"}, nil},
+		{"map entry", []string{"--label", "prod"}, nil, nil, []error{ErrConversion}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag, _ := c.AddOption("tag", []string{"tag"}, nil, "A tag")
+			tag.SetRepeatable(true)
+			tag.SetSeparator(",")
+			tag.SetCount(0, 3)
+			label, _ := c.AddOption("label", []string{"label"}, nil, "A label")
+			label.SetRepeatable(true)
+			label.SetMap(true)
+
+			err = c.Parse(tt.args)
+			checkErrors(t, err, tt.errs)
+			if err != nil {
+				return
+			}
+			if got := tag.GetValues(); fmt.Sprint(got) != fmt.Sprint(tt.tags) && len(got)+len(tt.tags) > 0 {
+				t.Errorf("tag GetValues() = %q, want %q", got, tt.tags)
+			}
+			if got, _ := label.GetMap(); fmt.Sprint(got) != fmt.Sprint(tt.labels) {
+				t.Errorf("label GetMap() = %v, want %v", got, tt.labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cliopatra
+
+// terminalWidth returns zero as the terminal size is not read on this platform
+func terminalWidth() int {
+	return 0
+}
@@ -0,0 +1,390 @@
+package cliopatra
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * CONSTANTS
+ */
+const (
+	ErrorByteSizeInvalid = "invalid byte size"
+	ErrorByteSizeUnit    = "unknown byte size unit"
+	ErrorFileModeInvalid = "invalid file mode"
+	ErrorURLInvalid      = "invalid URL. A scheme and host are required"
+)
+
+/*
+ * DERIVED CONSTANTS
+ */
+var (
+	byteSizePattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([A-Za-z]*)$`)
+	byteSizeUnits   = map[string]uint64{
+		"":    1,
+		"b":   1,
+		"k":   1000,
+		"kb":  1000,
+		"kib": 1 << 10,
+		"m":   1000 * 1000,
+		"mb":  1000 * 1000,
+		"mib": 1 << 20,
+		"g":   1000 * 1000 * 1000,
+		"gb":  1000 * 1000 * 1000,
+		"gib": 1 << 30,
+		"t":   1000 * 1000 * 1000 * 1000,
+		"tb":  1000 * 1000 * 1000 * 1000,
+		"tib": 1 << 40,
+		"p":   1000 * 1000 * 1000 * 1000 * 1000,
+		"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+		"pib": 1 << 50,
+	}
+)
+
+/*
+ * TYPES
+ */
+
+// Value is a typed parameter value. Set is called once per parse with the command line, environment,
+// config or default value. The same interface as flag.Value so those implementations may be used.
+// The built-in Values restore the value their pointer held when created before each parse.
+type Value interface {
+	Set(s string) error
+	String() string
+}
+
+type byteSizeValue struct {
+	initial uint64
+	p       *uint64
+}
+
+// ByteSizeValue returns a Value storing a size in bytes in p. Decimal (KB, MB, ...) and binary (KiB,
+// MiB, ...) units are accepted. i.e.: 10MiB
+func ByteSizeValue(p *uint64) Value {
+	return byteSizeValue{*p, p}
+}
+
+func (v byteSizeValue) Set(s string) error {
+	m := byteSizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return errors.New(ErrorByteSizeInvalid)
+	}
+	unit, ok := byteSizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return fmt.Errorf("%s %q", ErrorByteSizeUnit, m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || n*float64(unit) > math.MaxUint64 {
+		return errors.New(ErrorByteSizeInvalid)
+	}
+	*v.p = uint64(n * float64(unit))
+	return nil
+}
+
+func (v byteSizeValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	for _, u := range []string{"PiB", "TiB", "GiB", "MiB", "KiB"} {
+		size := byteSizeUnits[strings.ToLower(u)]
+		if *v.p >= size && *v.p%size == 0 {
+			return fmt.Sprintf("%d%s", *v.p/size, u)
+		}
+	}
+	return fmt.Sprintf("%dB", *v.p)
+}
+
+func (v byteSizeValue) reset() {
+	*v.p = v.initial
+}
+
+type cidrValue struct {
+	initial net.IPNet
+	p       *net.IPNet
+}
+
+// CIDRValue returns a Value storing an IP network in p. i.e.: 10.0.0.0/8
+func CIDRValue(p *net.IPNet) Value {
+	return cidrValue{*p, p}
+}
+
+func (v cidrValue) Set(s string) error {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return err
+	}
+	*v.p = *n
+	return nil
+}
+
+func (v cidrValue) String() string {
+	if v.p == nil || v.p.IP == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v cidrValue) reset() {
+	*v.p = v.initial
+}
+
+type durationValue struct {
+	initial time.Duration
+	p       *time.Duration
+}
+
+// DurationValue returns a Value storing a duration in p. i.e.: 1h30m
+func DurationValue(p *time.Duration) Value {
+	return durationValue{*p, p}
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v durationValue) reset() {
+	*v.p = v.initial
+}
+
+type fileModeValue struct {
+	initial os.FileMode
+	p       *os.FileMode
+}
+
+// FileModeValue returns a Value storing octal permission bits in p. i.e.: 0644
+func FileModeValue(p *os.FileMode) Value {
+	return fileModeValue{*p, p}
+}
+
+func (v fileModeValue) Set(s string) error {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 07777 {
+		return errors.New(ErrorFileModeInvalid)
+	}
+	mode := os.FileMode(m & 0777)
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	*v.p = mode
+	return nil
+}
+
+func (v fileModeValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	m := uint32(v.p.Perm())
+	if *v.p&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if *v.p&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if *v.p&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return fmt.Sprintf("%04o", m)
+}
+
+func (v fileModeValue) reset() {
+	*v.p = v.initial
+}
+
+type ipValue struct {
+	initial net.IP
+	p       *net.IP
+}
+
+// IPValue returns a Value storing an IPv4 or IPv6 address in p
+func IPValue(p *net.IP) Value {
+	return ipValue{*p, p}
+}
+
+func (v ipValue) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return &net.ParseError{Type: "IP address", Text: s}
+	}
+	*v.p = ip
+	return nil
+}
+
+func (v ipValue) String() string {
+	if v.p == nil || len(*v.p) == 0 {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v ipValue) reset() {
+	*v.p = v.initial
+}
+
+type regexpValue struct {
+	initial *regexp.Regexp
+	p       **regexp.Regexp
+}
+
+// RegexpValue returns a Value storing a compiled regular expression in p
+func RegexpValue(p **regexp.Regexp) Value {
+	return regexpValue{*p, p}
+}
+
+func (v regexpValue) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*v.p = re
+	return nil
+}
+
+func (v regexpValue) String() string {
+	if v.p == nil || *v.p == nil {
+		return ""
+	}
+	return (*v.p).String()
+}
+
+func (v regexpValue) reset() {
+	*v.p = v.initial
+}
+
+type timeValue struct {
+	initial time.Time
+	layout  string
+	p       *time.Time
+}
+
+// TimeValue returns a Value storing a timestamp in p parsed with the layout. time.RFC3339 if empty.
+func TimeValue(p *time.Time, layout string) Value {
+	if len(layout) == 0 {
+		layout = time.RFC3339
+	}
+	return timeValue{*p, layout, p}
+}
+
+func (v timeValue) Set(s string) error {
+	t, err := time.Parse(v.layout, s)
+	if err != nil {
+		return err
+	}
+	*v.p = t
+	return nil
+}
+
+func (v timeValue) String() string {
+	if v.p == nil || v.p.IsZero() {
+		return ""
+	}
+	return v.p.Format(v.layout)
+}
+
+func (v timeValue) reset() {
+	*v.p = v.initial
+}
+
+type urlValue struct {
+	initial url.URL
+	p       *url.URL
+}
+
+// URLValue returns a Value storing an absolute URL in p. i.e.: https://example.com/path
+func URLValue(p *url.URL) Value {
+	return urlValue{*p, p}
+}
+
+func (v urlValue) Set(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return errors.New(ErrorURLInvalid)
+	}
+	*v.p = *u
+	return nil
+}
+
+func (v urlValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v urlValue) reset() {
+	*v.p = v.initial
+}
+
+// setTypedValues passes the values of the parameters with a Value type, including defaults, to their
+// Value. Done for the command set and its parents after the command line, environment and config are read.
+func (cs *CommandSet) setTypedValues() error {
+	var errs ParseErrors
+	for set := cs; set != nil; set = set.parent {
+		for _, pk := range set.parameterKeys() {
+			pv := set.Parameters[pk]
+			var typed Value
+			values := []string{}
+			switch t := pv.(type) {
+			case *Argument:
+				typed = t.typed
+				values = t.values
+			case *Flag:
+				typed = t.typed
+			case *Option:
+				typed = t.typed
+				if t.repeatable {
+					values = t.GetValues()
+					if len(values) == 0 {
+						continue
+					}
+				}
+			}
+			if typed == nil {
+				continue
+			}
+			if len(values) == 0 {
+				s, err := pv.GetValue()
+				if err != nil {
+					continue
+				}
+				values = []string{s}
+			}
+			p := parameterOf(pv)
+			for _, s := range values {
+				if err := typed.Set(s); err != nil {
+					errs = append(errs, &ParseError{Cause: err, Err: ErrConversion, Index: p.Index, Key: pk, Value: s})
+					break
+				}
+			}
+		}
+	}
+	return errs.err()
+}
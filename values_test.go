@@ -0,0 +1,101 @@
+package cliopatra
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		newValue func() Value
+		initial  string
+		in       string
+		want     string
+		wantErr  bool
+	}{
+		{"byte size binary", func() Value { n := uint64(1 << 10); return ByteSizeValue(&n) }, "1KiB", "10MiB", "10MiB", false},
+		{"byte size decimal", func() Value { n := uint64(0); return ByteSizeValue(&n) }, "0B", "1.5KB", "1500B", false},
+		{"byte size spaced", func() Value { n := uint64(0); return ByteSizeValue(&n) }, "0B", " 2 kib", "2KiB", false},
+		{"byte size unit", func() Value { n := uint64(0); return ByteSizeValue(&n) }, "0B", "10XB", "0B", true},
+		{"byte size invalid", func() Value { n := uint64(0); return ByteSizeValue(&n) }, "0B", "-1", "0B", true},
+		{"cidr", func() Value { return CIDRValue(&net.IPNet{}) }, "", "10.0.0.0/8", "10.0.0.0/8", false},
+		{"cidr host bits", func() Value { return CIDRValue(&net.IPNet{}) }, "", "10.1.2.3/8", "10.0.0.0/8", false},
+		{"cidr invalid", func() Value { return CIDRValue(&net.IPNet{}) }, "", "10.0.0.0", "", true},
+		{"duration", func() Value { d := 5 * time.Second; return DurationValue(&d) }, "5s", "1h30m", "1h30m0s", false},
+		{"duration invalid", func() Value { d := 5 * time.Second; return DurationValue(&d) }, "5s", "5", "5s", true},
+		{"file mode", func() Value { m := os.FileMode(0600); return FileModeValue(&m) }, "0600", "0644", "0644", false},
+		{"file mode special", func() Value { m := os.FileMode(0600); return FileModeValue(&m) }, "0600", "7755", "7755", false},
+		{"file mode range", func() Value { m := os.FileMode(0600); return FileModeValue(&m) }, "0600", "10000", "0600", true},
+		{"file mode octal", func() Value { m := os.FileMode(0600); return FileModeValue(&m) }, "0600", "0988", "0600", true},
+		{"ip v4", func() Value { return IPValue(&net.IP{}) }, "", "127.0.0.1", "127.0.0.1", false},
+		{"ip v6", func() Value { return IPValue(&net.IP{}) }, "", "::1", "::1", false},
+		{"ip invalid", func() Value { return IPValue(&net.IP{}) }, "", "localhost", "", true},
+		{"regexp", func() Value { re := regexp.MustCompile("x"); return RegexpValue(&re) }, "x", "^a+$", "^a+$", false},
+		{"regexp invalid", func() Value { re := regexp.MustCompile("x"); return RegexpValue(&re) }, "x", "(", "x", true},
+		{"time", func() Value { return TimeValue(&time.Time{}, "") }, "", "2024-01-02T03:04:05Z", "2024-01-02T03:04:05Z", false},
+		{"time layout", func() Value { return TimeValue(&time.Time{}, "2006-01-02") }, "", "2024-01-02", "2024-01-02", false},
+		{"time invalid", func() Value { return TimeValue(&time.Time{}, "2006-01-02") }, "", "01/02/2024", "", true},
+		{"url", func() Value { return URLValue(&url.URL{}) }, "", "https://example.com/path", "https://example.com/path", false},
+		{"url relative", func() Value { return URLValue(&url.URL{}) }, "", "/path", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.newValue()
+			if got := v.String(); got != tt.initial {
+				t.Errorf("String() before Set = %q, want %q", got, tt.initial)
+			}
+			if err := v.Set(tt.in); (err != nil) != tt.wantErr {
+				t.Errorf("Set(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if got := v.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			v.(interface{ reset() }).reset()
+			if got := v.String(); got != tt.initial {
+				t.Errorf("String() after reset = %q, want %q", got, tt.initial)
+			}
+		})
+	}
+}
+
+// TestValueReset parses twice, the second parse restoring the value the Value's pointer held when created
+func TestValueReset(t *testing.T) {
+	c, err := New(CommandSet{Name: "app", IsGNU: true, Prefix: []string{"-", "--"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := 5 * time.Second
+	o, _ := c.AddOption("timeout", []string{"timeout"}, nil, "The timeout")
+	o.SetValueType(DurationValue(&timeout))
+
+	if err := c.Parse([]string{"--timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != time.Minute {
+		t.Errorf("timeout = %s, want 1m0s", timeout)
+	}
+	if err := c.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 5*time.Second {
+		t.Errorf("timeout after re-parse = %s, want 5s", timeout)
+	}
+}
+
+func TestValueConversion(t *testing.T) {
+	c, err := New(CommandSet{Name: "app", IsGNU: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := 5 * time.Second
+	o, _ := c.AddOption("timeout", []string{"timeout"}, nil, "The timeout")
+	o.SetValueType(DurationValue(&timeout))
+
+	checkErrors(t, c.Parse([]string{"--timeout", "soon"}), []error{ErrConversion})
+}